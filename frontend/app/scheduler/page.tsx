@@ -1,7 +1,7 @@
 "use client";
 
 import type React from "react";
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import Image from "next/image";
 import { useRouter } from "next/navigation";
 
@@ -15,30 +15,38 @@ import ClassTable from "@/components/scheduler/class-table";
 import { BACKEND_URL } from "@/lib/constants";
 import { Section } from "@/lib/classes";
 
+// the one schedule this page autosaves to
+const CURRENT_SCHEDULE_ID = "current";
+
 export default function SchedulerPage() {
     const router = useRouter();
 
     // NOTE: loading is true while we check auth status
     // isLoading is true while we fetch schedule data
     // i know confusing names lol but whatever i'll fix later
-    const { user, loading } = useAuth();
+    const { user, csrfToken, loading } = useAuth();
 
     const [isLoading, setIsLoading] = useState(true);
     const [currentSchedule, setCurrentSchedule] = useState<Section[]>([]);
     const [activeTab, setActiveTab] = useState("Current");
 
-    // shared handler to update schedule from child components
-    const handleScheduleUpdate = async (newSchedule: Section[]) => {
-        // optimistic update so UI feels snappy
-        setCurrentSchedule(newSchedule);
+    // revision of the stored schedule, sent back with every save so the backend
+    // can tell when another tab saved in between (0 = nothing saved yet)
+    const revision = useRef(0);
+    // saves go out one at a time, each one needs the revision the previous one returned
+    const saveQueue = useRef<Promise<void>>(Promise.resolve());
+    // bumped on a conflict, edits queued before it were made on top of the stale schedule
+    const generation = useRef(0);
 
+    const saveSchedule = async (newSchedule: Section[]) => {
         if (!user || !user.UserID) return;
 
         const payload = {
-            id: "current",
+            id: CURRENT_SCHEDULE_ID,
             userId: user.UserID,
             name: "Current Semester",
             sections: newSchedule,
+            revision: revision.current,
         };
 
         try {
@@ -46,17 +54,45 @@ export default function SchedulerPage() {
                 `${BACKEND_URL}/api/users/${user.UserID}/schedules`,
                 {
                     method: "POST",
-                    headers: { "Content-Type": "application/json" },
+                    credentials: "include",
+                    headers: {
+                        "Content-Type": "application/json",
+                        "X-CSRF-Token": csrfToken ?? "",
+                    },
                     body: JSON.stringify(payload),
                 }
             );
+            if (res.status === 409) {
+                // another tab or device saved first, show what's stored now instead of overwriting it
+                const data = await res.json();
+                console.warn("Schedule changed elsewhere, reloading it");
+                generation.current++;
+                revision.current = data.current?.revision ?? 0;
+                setCurrentSchedule(data.current?.sections ?? []);
+                return;
+            }
             if (!res.ok) throw new Error("Failed to save");
+
+            const data = await res.json();
+            revision.current = data.revision;
             console.log("Auto-saved to Firestore");
         } catch (error) {
             console.error("Auto-save failed:", error);
         }
     };
 
+    // shared handler to update schedule from child components
+    const handleScheduleUpdate = (newSchedule: Section[]) => {
+        // optimistic update so UI feels snappy
+        setCurrentSchedule(newSchedule);
+
+        const queuedIn = generation.current;
+        saveQueue.current = saveQueue.current.then(() => {
+            if (queuedIn !== generation.current) return;
+            return saveSchedule(newSchedule);
+        });
+    };
+
     useEffect(() => {
         if (!user || !user.UserID) return;
 
@@ -64,14 +100,19 @@ export default function SchedulerPage() {
             try {
                 // fetch users schedules
                 const res = await fetch(
-                    `${BACKEND_URL}/api/users/${user.UserID}/schedules`
+                    `${BACKEND_URL}/api/users/${user.UserID}/schedules`,
+                    { credentials: "include" }
                 );
                 if (res.ok) {
                     const data = await res.json();
                     console.log("Loaded schedules:", data);
-                    // if they have a saved schedule, load the sections from the first one
-                    if (data && data.length > 0) {
-                        setCurrentSchedule(data[0].sections);
+                    // load the autosaved schedule, and its revision for the next save
+                    const current = data?.find(
+                        (s: { id: string }) => s.id === CURRENT_SCHEDULE_ID
+                    );
+                    if (current) {
+                        revision.current = current.revision;
+                        setCurrentSchedule(current.sections);
                     }
                 }
             } catch (err) {
@@ -6,12 +6,23 @@ export interface Meeting {
     location: string; // "HORIZN 2014"
 }
 
+// someone teaching a section
+export interface Instructor {
+    id: string; // "jsmith@gmu.edu", or "name:smith, john" without an email
+    name: string; // "Smith, John"
+    email?: string;
+    primary: boolean;
+}
+
 // section within a course chosen by the user
 export interface Section {
-    id: string; // CRN ex) "17837"
+    id: string; // term and CRN ex) "202610-17837"
+    crn: string; // "17837", only unique within a term
+    term: string; // "202610"
     course_id: string; // "CS100" - link to parent course collection
     section: string; // "001" - section number
-    professor: string; // "Smith, John"
+    professor: string; // "Smith, John" - the primary instructor
+    instructors: Instructor[]; // everyone teaching it, primary first
 
     meetings: Meeting[];
 }
@@ -19,9 +19,27 @@ export interface User {
 
 export function useAuth() {
     const [user, setUser] = useState<User | null>(null);
+    const [csrfToken, setCsrfToken] = useState<string | null>(null);
     const [loading, setLoading] = useState(true);
     const router = useRouter();
 
+    // backend rejects POST/PUT/DELETE without this header
+    // the token belongs to the browser session, guests need it too
+    const fetchCsrfToken = async () => {
+        try {
+            const res = await fetch(`${BACKEND_URL}/auth/csrf`, {
+                method: "GET",
+                credentials: "include",
+            });
+            if (res.ok) {
+                const { csrf_token } = await res.json();
+                setCsrfToken(csrf_token);
+            }
+        } catch (error) {
+            console.error("Failed to fetch CSRF token", error);
+        }
+    };
+
     useEffect(() => {
         const fetchUser = async () => {
             try {
@@ -40,6 +58,7 @@ export function useAuth() {
                 console.error("Failed to fetch user", error);
                 setUser(null);
             } finally {
+                await fetchCsrfToken();
                 setLoading(false);
             }
         };
@@ -54,15 +73,17 @@ export function useAuth() {
                 method: "GET",
                 credentials: "include",
             });
-            // clear local state
+            // clear local state, the old session's token is gone with it
             setUser(null);
+            setCsrfToken(null);
+            await fetchCsrfToken();
             router.refresh();
         } catch (error) {
             console.error("Error signing out", error);
         }
     };
 
-    return { user, loading, handleSignOut };
+    return { user, csrfToken, loading, handleSignOut };
 }
 
 export const dayMap = ["Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"];
@@ -0,0 +1,348 @@
+package main
+
+// dumps courses, sections, users and their schedules to JSONL files, and loads them back
+// one file per collection (courses.jsonl, sections.jsonl, users.jsonl, schedules.jsonl),
+// one document per line, in the same shape the API returns
+//
+// run with: go run ./cmd/backup export -dir ./backup
+//     then: go run ./cmd/backup import -dir ./backup
+//
+// uses the same STORAGE_BACKEND env as the server, so pointing the import at another
+// GOOGLE_PROJECT_ID or at FIRESTORE_EMULATOR_HOST copies data between environments.
+//
+// -collections courses,sections only touches those files
+// -term 202610 keeps only sections of that term (and the courses they belong to),
+// users and schedules aren't tied to a term so they're not affected
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/backend"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/jsonl"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"github.com/joho/godotenv"
+)
+
+var allCollections = []string{"courses", "sections", "users", "schedules"}
+
+// schedules can be a few hundred KB each once they hold a lot of sections
+const maxLine = 16 << 20
+
+type options struct {
+	dir         string
+	collections []string
+	term        string
+}
+
+func (o options) wants(collection string) bool {
+	return slices.Contains(o.collections, collection)
+}
+
+func (o options) file(collection string) string {
+	return filepath.Join(o.dir, collection+".jsonl")
+}
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "export" && os.Args[1] != "import") {
+		fmt.Fprintln(os.Stderr, "usage: backup export|import [-dir ./backup] [-collections courses,sections,users,schedules] [-term 202610]")
+		os.Exit(2)
+	}
+	mode := os.Args[1]
+
+	fs := flag.NewFlagSet(mode, flag.ExitOnError)
+	dir := fs.String("dir", "backup", "directory holding the .jsonl files")
+	collections := fs.String("collections", strings.Join(allCollections, ","), "comma separated collections to include")
+	term := fs.String("term", "", "only sections of this banner term, ex) 202610")
+	fs.Parse(os.Args[2:])
+
+	opts := options{dir: *dir, term: *term}
+	for _, c := range strings.Split(*collections, ",") {
+		c = strings.TrimSpace(c)
+		if !slices.Contains(allCollections, c) {
+			log.Fatalf("unknown collection %q, expected one of %s", c, strings.Join(allCollections, ", "))
+		}
+		opts.collections = append(opts.collections, c)
+	}
+
+	// .env is optional here, env can come from the shell too
+	if err := godotenv.Load(); err != nil {
+		log.Println("no .env file, using the environment as-is")
+	}
+
+	store, closeStore, err := backend.Open()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	if mode == "export" {
+		err = runExport(ctx, store, opts)
+	} else {
+		err = runImport(ctx, store, opts)
+	}
+	if err != nil {
+		closeStore()
+		log.Fatal(err)
+	}
+}
+
+//
+// --- export ---
+
+func runExport(ctx context.Context, store storage.Store, opts options) error {
+	if err := os.MkdirAll(opts.dir, 0o755); err != nil {
+		return err
+	}
+
+	// courses only carry section IDs, so the term filter goes through the sections
+	var sections []types.Section
+	if opts.wants("sections") || (opts.wants("courses") && opts.term != "") {
+		var err error
+		if sections, err = store.Sections.ListSections(ctx, opts.term); err != nil {
+			return fmt.Errorf("failed to list sections: %w", err)
+		}
+	}
+
+	if opts.wants("courses") {
+		courses, err := store.Courses.ListCourses(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list courses: %w", err)
+		}
+		if opts.term != "" {
+			courses = coursesWithSections(courses, sections)
+		}
+		if err := writeJSONL(opts.file("courses"), courses); err != nil {
+			return err
+		}
+	}
+
+	if opts.wants("sections") {
+		if err := writeJSONL(opts.file("sections"), sections); err != nil {
+			return err
+		}
+	}
+
+	if !opts.wants("users") && !opts.wants("schedules") {
+		return nil
+	}
+
+	users, err := store.Users.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	if opts.wants("users") {
+		if err := writeJSONL(opts.file("users"), users); err != nil {
+			return err
+		}
+	}
+
+	if opts.wants("schedules") {
+		// schedules are nested under users, each line keeps its user_id so it can go back there
+		schedules := []types.Schedule{}
+		for _, u := range users {
+			list, err := store.Schedules.ListSchedules(ctx, u.ID)
+			if err != nil {
+				return fmt.Errorf("failed to list schedules of %s: %w", u.ID, err)
+			}
+			for _, s := range list {
+				s.UserID = u.ID
+				schedules = append(schedules, s)
+			}
+		}
+		if err := writeJSONL(opts.file("schedules"), schedules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// courses that have at least one of the given sections
+func coursesWithSections(courses []types.Course, sections []types.Section) []types.Course {
+	keep := make(map[string]bool)
+	for _, s := range sections {
+		keep[s.CourseID] = true
+	}
+
+	filtered := []types.Course{}
+	for _, c := range courses {
+		if keep[c.ID] {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// jsonl.Write plus a log line, so an export shows what it wrote
+func writeJSONL[T any](path string, docs []T) error {
+	if err := jsonl.Write(path, docs); err != nil {
+		return err
+	}
+	log.Printf("exported %d documents to %s", len(docs), path)
+	return nil
+}
+
+//
+// --- import ---
+
+// writes go through the normal repositories, so documents get stamped and linked like a scrape.
+// a failed document is logged and skipped, the import returns an error at the end if any failed
+func runImport(ctx context.Context, store storage.Store, opts options) error {
+	failed := 0
+
+	var courses []types.Course
+	var sections []types.Section
+	if opts.wants("courses") {
+		if err := readJSONL(opts.file("courses"), &courses); err != nil {
+			return err
+		}
+	}
+	if opts.wants("sections") {
+		if err := readJSONL(opts.file("sections"), &sections); err != nil {
+			return err
+		}
+		if opts.term != "" {
+			sections = slices.DeleteFunc(sections, func(s types.Section) bool { return s.Term != opts.term })
+		}
+	}
+	if opts.term != "" && opts.wants("courses") {
+		// without the sections file there's nothing to tell which courses are in the term
+		if !opts.wants("sections") {
+			return errors.New("-term with courses needs sections too")
+		}
+		courses = coursesWithSections(courses, sections)
+	}
+
+	byCourse := make(map[string][]types.Section)
+	for _, s := range sections {
+		byCourse[s.CourseID] = append(byCourse[s.CourseID], s)
+	}
+
+	// a course and its sections land together, like the scraper saves them
+	for _, c := range courses {
+		secs := byCourse[c.ID]
+		delete(byCourse, c.ID)
+
+		var err error
+		if len(secs) == 0 {
+			err = store.Courses.SaveCourse(ctx, c)
+		} else {
+			err = store.Sections.SaveCourseSections(ctx, c, secs)
+		}
+		failed += reportFailure("courses", c.ID, err)
+	}
+
+	// sections whose course isn't in the backup get linked if the course already exists
+	for _, secs := range byCourse {
+		for _, s := range secs {
+			failed += reportFailure("sections", s.ID, store.Sections.SaveSection(ctx, s))
+		}
+	}
+	if opts.wants("courses") || opts.wants("sections") {
+		log.Printf("imported %d courses, %d sections", len(courses), len(sections))
+	}
+
+	if opts.wants("users") {
+		var users []types.User
+		if err := readJSONL(opts.file("users"), &users); err != nil {
+			return err
+		}
+		for _, u := range users {
+			failed += reportFailure("users", u.ID, store.Users.SaveUser(ctx, u))
+		}
+		log.Printf("imported %d users", len(users))
+	}
+
+	if opts.wants("schedules") {
+		var schedules []types.Schedule
+		if err := readJSONL(opts.file("schedules"), &schedules); err != nil {
+			return err
+		}
+		for _, s := range schedules {
+			failed += reportFailure("schedules", s.UserID+"/"+s.ID, importSchedule(ctx, store, s))
+		}
+		log.Printf("imported %d schedules", len(schedules))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d documents failed to import", failed)
+	}
+	return nil
+}
+
+// the backup wins over whatever is stored, so the save is based on the stored revision
+func importSchedule(ctx context.Context, store storage.Store, s types.Schedule) error {
+	if s.UserID == "" {
+		return errors.New("missing user_id")
+	}
+
+	s.Revision = 0
+	current, err := store.Schedules.GetSchedule(ctx, s.UserID, s.ID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if current != nil {
+		s.Revision = current.Revision
+	}
+
+	_, err = store.Schedules.SaveSchedule(ctx, s.UserID, s)
+	return err
+}
+
+// logs err and returns how many documents it stands for
+func reportFailure(collection, id string, err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var batchErr *storage.BatchError
+	if errors.As(err, &batchErr) {
+		for _, f := range batchErr.Failures {
+			log.Printf("failed to import %s/%s: %v", f.Collection, f.ID, f.Err)
+		}
+		return len(batchErr.Failures)
+	}
+
+	log.Printf("failed to import %s/%s: %v", collection, id, err)
+	return 1
+}
+
+// a missing file is skipped with a warning, the backup may have been taken with -collections
+func readJSONL[T any](path string, docs *[]T) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("%s not found, skipping", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+
+		var doc T
+		if err := json.Unmarshal(sc.Bytes(), &doc); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		*docs = append(*docs, doc)
+	}
+	return sc.Err()
+}
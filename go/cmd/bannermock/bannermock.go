@@ -0,0 +1,65 @@
+package main
+
+// a local banner to point the scraper at, see internal/bannermock
+// run with: go run ./cmd/bannermock
+// then:     BANNER_BASE_URL=http://localhost:8081/StudentRegistrationSsb go run ./cmd/scraper -terms auto -subjects all
+//
+//	-data dataset.json     sections to serve, a Dataset file or a directory of raw banner pages
+//	                       (cmd/scraper -out dir -raw writes those to dir/raw). the built-in sample without it
+//	-dump sample.json      write the dataset being served to a file and exit, a starting point for editing
+//	-fail-rate 0.1         answer that share of requests with a 503
+//	-expire-after 5        sessions die after that many searches
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/bannermock"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	dataPath := flag.String("data", "", "dataset file or directory of raw pages, empty for the built-in sample")
+	dump := flag.String("dump", "", "write the dataset to this file and exit")
+	failRate := flag.Float64("fail-rate", 0, "share of requests answered with a 503")
+	expireAfter := flag.Int("expire-after", 0, "searches before a session expires, 0 for never")
+	flag.Parse()
+
+	data := bannermock.Sample()
+	if *dataPath != "" {
+		var err error
+		if data, err = bannermock.Load(*dataPath); err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+	}
+
+	if *dump != "" {
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*dump, out, 0644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %d terms, %d subjects, %d sections to %s", len(data.Terms), len(data.Subjects), len(data.Sections), *dump)
+		return
+	}
+
+	server := bannermock.New(data, bannermock.Options{FailRate: *failRate, ExpireAfter: *expireAfter})
+
+	log.Printf("bannermock: %d terms, %d subjects, %d sections on %s%s",
+		len(data.Terms), len(data.Subjects), len(data.Sections), *addr, bannermock.Prefix)
+	log.Fatal(http.ListenAndServe(*addr, logRequests(server)))
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s (%s)", r.Method, r.URL.RequestURI(), time.Since(start).Round(time.Microsecond))
+	})
+}
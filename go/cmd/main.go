@@ -8,8 +8,8 @@ import (
 
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/api"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/auth"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/backend"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/catalog"
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -22,15 +22,17 @@ func main() {
 		log.Fatal(".env file failed to load")
 	}
 
-	// init firestore client
-	if err := firestore.Init(); err != nil {
-		log.Printf("Failed to initialize Firestore: %v", err)
+	// init storage, firestore unless STORAGE_BACKEND says otherwise
+	// nothing works without storage, so this is fatal
+	store, closeStore, err := backend.Open()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer firestore.Close()
+	defer closeStore()
 
 	// warm up course cache
 	// this is a startup task so use context.Background()
-	if err := catalog.LoadCache(context.Background(), firestore.Client); err != nil {
+	if err := catalog.LoadCache(context.Background(), store.Courses); err != nil {
 		// CRITICAL: stop startup if this fails.
 		// the search feature will be broken otherwise and we can't serve requests properly.
 		log.Fatalf("Failed to warm up course cache: %v", err)
@@ -44,8 +46,13 @@ func main() {
 	config.AllowOrigins = []string{os.Getenv("FRONTEND_URL")}
 	config.AllowCredentials = true
 	config.AddAllowMethods("GET", "POST", "PUT", "DELETE")
+	config.AddAllowHeaders(auth.CSRFHeader, "Authorization")
 	r.Use(cors.New(config))
 
+	// CSRF protection for every mutating route
+	// GET/HEAD/OPTIONS pass through, everything else needs the X-CSRF-Token header
+	r.Use(auth.CSRFMiddleware())
+
 	// health check route
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "health check ok"})
@@ -56,25 +63,59 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"message": "GDG GMU Dormant API"})
 	})
 
-	// initialize authentication
-	auth.NewAuth()
+	// initialize authentication and API handlers
+	a := auth.NewAuth(store)
+	h := api.NewHandler(store)
 
 	//  authentication routes
 	r.GET("/auth/:provider", auth.SignInWithProvider)
-	r.GET("/auth/:provider/callback", auth.CallbackHandler)
+	r.GET("/auth/:provider/callback", a.CallbackHandler)
 	r.GET("/auth/signout", auth.SignOutHandler)
-	r.GET("/auth/profile", auth.GetUserProfile)
+	r.GET("/auth/profile", a.RequireUser(), a.GetUserProfile)
+	r.GET("/auth/csrf", auth.GetCSRFToken)
 
 	// generator route
-	r.POST("/api/generate", api.GenerateSchedule)
+	r.POST("/api/generate", h.GenerateSchedule)
 
 	// course sections route
-	r.GET("/api/search", api.HandleSearchCourses)
-	r.GET("/api/sections", api.HandleGetSections)
+	r.GET("/api/search", h.HandleSearchCourses)
+	r.GET("/api/sections", h.HandleGetSections)
 
 	// user schedule routes
-	r.POST("/api/users/:userID/schedules", api.SaveCurrentSchedule)
-	r.GET("/api/users/:userID/schedules", api.GetSavedCurrentSchedules)
+	// session cookie or API token, and only for the caller's own userID
+	users := r.Group("/api/users/:userID", a.RequireUser())
+	users.POST("/schedules", h.SaveCurrentSchedule)
+	users.GET("/schedules", h.GetSavedCurrentSchedules)
+	users.GET("/schedules/:scheduleID/versions", h.GetScheduleVersions)
+	users.GET("/schedules/:scheduleID/versions/:version", h.GetScheduleVersion)
+	users.POST("/schedules/:scheduleID/versions/:version/restore", h.RestoreScheduleVersion)
+
+	// guest schedule routes
+	// anonymous session, merged into the account on sign in
+	guest := r.Group("/api/guest", auth.RequireGuest())
+	guest.POST("/schedules", h.SaveGuestSchedule)
+	guest.GET("/schedules", h.GetGuestSchedules)
+
+	// personal API token routes
+	tokens := r.Group("/api/tokens", a.RequireUser())
+	tokens.POST("", a.CreateAPIToken)
+	tokens.GET("", a.ListAPITokens)
+	tokens.DELETE("/:tokenID", a.RevokeAPIToken)
+
+	// account self-service routes
+	account := r.Group("/api/account", a.RequireUser())
+	account.GET("/export", a.ExportAccount)
+	account.DELETE("", a.DeleteAccount)
+
+	// admin routes
+	admin := r.Group("/api/admin", a.RequireUser(), a.RequireAdmin())
+	admin.POST("/catalog/reload", h.ReloadCatalog)
+	admin.GET("/stats", h.GetStats)
+	admin.POST("/scraper/runs", h.TriggerScrape)
+	admin.GET("/scraper/runs", h.GetScrapeRuns)
+	admin.GET("/scraper/runs/:runID", h.GetScrapeRun)
+	admin.GET("/scraper/runs/:runID/changes", h.GetScrapeChanges)
+	admin.GET("/scraper/terms", h.GetBannerTerms)
 
 	r.Run(":5000")
 }
@@ -0,0 +1,63 @@
+package main
+
+// upgrades stored courses, sections and schedules to the current schema version
+// see internal/schema for the version history
+// run with: go run ./cmd/migrate -dry-run
+// then:     go run ./cmd/migrate
+//
+// uses the same STORAGE_BACKEND env as the server.
+// readers already upgrade on the fly, this just makes the stored data catch up
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/backend"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report outdated documents without writing anything")
+	flag.Parse()
+
+	// .env is optional here, env can come from the shell too
+	if err := godotenv.Load(); err != nil {
+		log.Println("no .env file, using the environment as-is")
+	}
+
+	store, closeStore, err := backend.Open()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer closeStore()
+
+	fmt.Printf("current schema: courses v%d, sections v%d, schedules v%d\n",
+		schema.CourseVersion(), schema.SectionVersion(), schema.ScheduleVersion())
+	if *dryRun {
+		fmt.Println("dry run, nothing will be written")
+	}
+
+	results, err := store.Schema.MigrateDocuments(context.Background(), *dryRun)
+	if err != nil {
+		log.Fatalf("migration failed: %v", err)
+	}
+
+	failed := false
+	fmt.Printf("%-12s %8s %8s %8s %8s\n", "collection", "scanned", "outdated", "upgraded", "failed")
+	for _, r := range results {
+		fmt.Printf("%-12s %8d %8d %8d %8d\n", r.Collection, r.Scanned, r.Outdated, r.Upgraded, len(r.Failed))
+		if len(r.Failed) > 0 {
+			failed = true
+			fmt.Printf("   > failed %s: %s\n", r.Collection, strings.Join(r.Failed, ", "))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -1,46 +1,52 @@
 package main
 
 // scraper for GMU courses
-// performs guest handshake to get session cookie + synchronizer token
-// then fetches course data for a given subject and term
-// outputs the raw JSON response to stdout
+// thin wrapper around internal/scraper so it can also be triggered from the admin API
+// run with: go run ./cmd/scraper
 //
+//	-terms 202610,202620   banner term codes, or "auto" for every term that's still open
+//	-subjects CS,MATH      subject codes, or "all" for every subject in the term
+//	-list-terms            print the terms banner has and exit
+//	-workers 8             goroutines writing to storage
+//	-rate 2                banner requests per second
+//	-timeout 1h            give up on the whole run after this, 0 for no limit
+//	-out ./scrape          write to files in this directory instead of storage
+//	-format json           json for a STORAGE_FIXTURE file, jsonl for files cmd/backup can import
+//	-raw                   with -out, also keep every banner page as it came in (out/raw)
+//	-dry-run               scrape into a copy of storage and print what would change
+//	-record ./fixtures     save every banner request and response to this directory
+//	-replay ./fixtures     answer from a -record directory instead of banner, no network needed
+//	-daemon                keep running and scrape on a schedule, see internal/scraper/daemon.go
+//	-interval 6h           time between daemon runs
+//	-fast-interval 30m     time between daemon runs during registration windows
+//	-windows 2026-03-30/2026-04-20,2026-10-26/2026-11-16   registration windows, dates in local time
+//
+// flags default to SCRAPER_TERMS / SCRAPER_SUBJECTS, and to 202610 with CS and MATH when those aren't set
+// (same for SCRAPER_WORKERS, SCRAPER_RATE, SCRAPER_TIMEOUT, SCRAPER_INTERVAL, SCRAPER_FAST_INTERVAL and SCRAPER_WINDOWS)
+//
+// ctrl-c cancels the run: queued writes are dropped, running ones finish, and the run
+// is recorded as failed. a second ctrl-c kills it right away. the daemon stops the same way
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/http/cookiejar"
-	"net/url"
 	"os"
-	"regexp"
-	"strconv"
+	"os/signal"
+	"path/filepath"
 	"strings"
-	"sync"
-	"time"
+	"syscall"
 
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/backend"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/scraper"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 	"github.com/joho/godotenv"
 )
 
-const (
-	BaseURL = "https://ssbstureg.gmu.edu/StudentRegistrationSsb"
-	Term    = "202610" // spring 2026 term
-)
-
 func main() {
-	// initialize HTTP client with cookie jar
-	// NOTE: handles JSESSIONID automatically
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{
-		Jar:     jar,
-		Timeout: 15 * time.Second,
-	}
-
 	// initialize firestore
 	// NOTE: this is not an API endpoint, so we init firebase here
 	err := godotenv.Load()
@@ -48,348 +54,162 @@ func main() {
 		log.Fatal(".env file failed to load")
 	}
 
-	if err := firestore.Init(); err != nil {
-		log.Printf("Failed to initialize Firestore: %v", err)
-	}
-	defer firestore.Close()
-
-	// guest handshake to get X-Synchronizer-Token
-	fmt.Println("== 1 == visiting Search Page to get Token...")
-
-	// visit the main page just to parse the token from the HTML
-	targetURL := BaseURL + "/ssb/classSearch/classSearch"
-
-	req, _ := http.NewRequest("GET", targetURL, nil)
-	setHeaders(req, "")
-
-	resp, err := client.Do(req)
+	// env is loaded first so it can provide the flag defaults
+	defaults := scraper.OptionsFromEnv()
+	terms := flag.String("terms", listFlag(defaults.Terms, scraper.AutoTerms), `comma separated term codes, or "auto"`)
+	subjects := flag.String("subjects", listFlag(defaults.Subjects, scraper.AllSubjects), `comma separated subject codes, or "all"`)
+	listTerms := flag.Bool("list-terms", false, "print the terms banner offers and exit")
+	workers := flag.Int("workers", defaults.Workers, "goroutines writing to storage")
+	rate := flag.Float64("rate", defaults.Rate, "banner requests per second")
+	timeout := flag.Duration("timeout", defaults.Timeout, "cancel the run after this long, 0 for no limit")
+	out := flag.String("out", "", "write courses and sections to files in this directory instead of storage")
+	format := flag.String("format", scraper.FormatJSON, `file format for -out, "json" or "jsonl"`)
+	raw := flag.Bool("raw", false, "with -out, also save the raw banner pages")
+	dryRun := flag.Bool("dry-run", false, "scrape into a copy of storage and print what would change")
+	record := flag.String("record", defaults.RecordDir, "record banner traffic to this directory")
+	replay := flag.String("replay", defaults.ReplayDir, "replay banner traffic from this directory instead of the network")
+	schedule := scraper.ScheduleFromEnv()
+	daemon := flag.Bool("daemon", false, "keep running and scrape on a schedule")
+	interval := flag.Duration("interval", schedule.Interval, "time between daemon runs")
+	fastInterval := flag.Duration("fast-interval", schedule.FastInterval, "time between daemon runs during registration windows")
+	windows := flag.String("windows", os.Getenv("SCRAPER_WINDOWS"), "registration windows, comma separated start/end dates")
+	flag.Parse()
+
+	if *raw && *out == "" {
+		log.Fatal("-raw needs -out")
+	}
+	if *daemon && (*out != "" || *dryRun) {
+		log.Fatal("-daemon writes to storage, it can't be combined with -out or -dry-run")
+	}
+	if *interval <= 0 || *fastInterval <= 0 {
+		log.Fatal("-interval and -fast-interval have to be positive")
+	}
+	schedule.Interval = *interval
+	schedule.FastInterval = *fastInterval
+	if schedule.Windows, err = scraper.ParseWindows(*windows); err != nil {
+		log.Fatalf("Invalid -windows: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// back to the default handler, so the next signal kills us
+		stop()
+		log.Print("interrupted, stopping the scrape...")
+	}()
+
+	if *listTerms {
+		printTerms(ctx, scraper.Options{RecordDir: *record, ReplayDir: *replay})
+		return
+	}
+
+	store, closeStore := openStore(ctx, *out, *dryRun)
+	defer closeStore()
+
+	opts := scraper.ParseOptions(*terms, *subjects)
+	opts.Workers = *workers
+	opts.Rate = *rate
+	opts.Timeout = *timeout
+	opts.RecordDir = *record
+	opts.ReplayDir = *replay
+	if *raw {
+		opts.RawDir = filepath.Join(*out, "raw")
+	}
+
+	if *daemon {
+		// only returns once interrupted, every run is in scrape_runs
+		scraper.Daemon(ctx, store, opts, schedule)
+		log.Print("scrape daemon stopped")
+		return
+	}
+
+	run, err := scraper.Run(ctx, store, "cli", opts)
 	if err != nil {
+		closeStore()
 		log.Fatal(err)
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	bodyString := string(bodyBytes)
-
-	if resp.StatusCode != 200 {
-		log.Fatalf("Server returned %d", resp.StatusCode)
-	}
-
-	// regex to find window.synchronizerToken
-	re := regexp.MustCompile(`name="synchronizerToken"\s+content="([^"]+)"`)
-	matches := re.FindStringSubmatch(bodyString)
-
-	if len(matches) < 2 {
-		// dumping HTML page for debugging
-		os.WriteFile("debug_page.html", bodyBytes, 0644)
-		log.Fatal("Could not find X-Synchronizer-Token in HTML.")
-	}
-	token := matches[1]
-	fmt.Printf("   > Token Found: %s\n", token)
-
-	// setting the term
-	// all requests will happen after setting the term
-	fmt.Println("== 2 == setting term to", Term, "...")
-
-	formData := url.Values{}
-	formData.Set("term", Term)
-	formData.Set("studyPath", "")
-	formData.Set("studyPathText", "")
-	formData.Set("startDatepicker", "")
-	formData.Set("endDatepicker", "")
-
-	// NOTE: use the "uniqueSessionId" just to be safe
-	uniqueID := fmt.Sprintf("guest%d", time.Now().Unix())
-	termUrl := fmt.Sprintf("%s/ssb/term/search?mode=search&uniqueSessionId=%s", BaseURL, uniqueID)
 
-	req, _ = http.NewRequest("POST", termUrl, strings.NewReader(formData.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	log.Printf("scrape %s %s: terms %v, %d courses, %d sections, %d errors",
+		run.ID, run.Status, run.Terms, run.Courses, run.Sections, len(run.Errors))
 
-	// attach the token header
-	setHeaders(req, token)
-
-	resp, err = client.Do(req)
-	if err != nil {
-		log.Fatal(err)
+	if *dryRun {
+		printChanges(store, run)
 	}
-	resp.Body.Close()
-
-	// search for classes
-	// use this to get all subjects in prod
-	// subjects, err := GetSubjects(client, token)
-
-	// in local we are only testing CS and MATH
-	var subjects = []string{"CS", "MATH"}
-	fmt.Printf("== 3 == fetching classes for %s...\n", subjects)
-
-	// for concurrency
-	var wg sync.WaitGroup
-
-	// caching to avoid writing the same course metadata million times
-	var courseMu sync.Mutex
-	// using a set to track seen courses
-	seenCourses := make(map[string]bool)
-
-	for _, subj := range subjects {
-		// NOTE: banner api is stateful. which means we need to reset the search
-		// every time we change the subject
-		// otherwise it will keep appending to the previous search
-		resetSearch(client, token)
-
-		offset := 0
-		maxSize := 50
-
-		for {
-			fmt.Printf("fetching %s (offset %d)...\n", subj, offset)
-
-			// build URL with dynamic offset
-			apiURL := fmt.Sprintf(
-				"%s/ssb/searchResults/searchResults?txt_subject=%s&txt_term=%s&pageOffset=%d&pageMaxSize=%d",
-				BaseURL, subj, Term, offset, maxSize,
-			)
-
-			// fetch
-			req, _ = http.NewRequest("GET", apiURL, nil)
-			// attach the token header
-			setHeaders(req, token)
-
-			resp, err = client.Do(req)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer resp.Body.Close()
-
-			bodyBytes, _ = io.ReadAll(resp.Body)
-
-			if resp.StatusCode != 200 {
-				log.Fatalf("error %d: %s", resp.StatusCode, string(bodyBytes))
-			}
-
-			fmt.Printf("received %d bytes of JSON.\n", len(bodyBytes))
-			fmt.Println(string(bodyBytes[:1000]))
-
-			// unmarshal into struct
-			var response types.BannerResponse
-			json.Unmarshal(bodyBytes, &response)
-
-			if len(response.Data) == 0 {
-				break
-			}
-
-			// process and save
-			for _, rawSec := range response.Data {
-
-				// NOTE: we save course and section data separately
-				// in different root collection in firestore.
-				// this is to avoid data being 1mb< and hitting firebase document limit
-				// also it allows us to use lazy loading for courses
-				// fetch courses -> fetch sections on demand
-
-				// + why not subcollection?
-				// query limitation. to use collection group queries,
-				// we cannot have subcollections:
-				// fs.Get("10492") is logically sound than fs.Get("courses/CS110/sections/10492")
-				// when we want extra search features later
-				// "give me all sections taught by prof goof" for example.
-
-				// save course info if not seen before
-				// do this synchronously to ensure we don't spam firestore with
-				// the same course title over and over
-				courseID := rawSec.Subject + rawSec.CourseNumber
-				courseMu.Lock()
-				if !seenCourses[courseID] {
-					seenCourses[courseID] = true
-
-					// course object
-					course := types.Course{
-						ID:         courseID,
-						Department: rawSec.Subject,
-						Code:       rawSec.CourseNumber,
-						Title:      rawSec.Title,
-					}
-
-					go func(c types.Course) {
-						if err := firestore.SaveCourse(context.Background(), c); err != nil {
-							log.Printf("Error saving course %s: %v", c.ID, err)
-						}
-					}(course)
-				}
-				courseMu.Unlock()
-
-				// save section data
-				wg.Add(1)
-				go func(raw types.BannerSection) {
-					defer wg.Done()
-
-					cleanSec := parseBannerSection(raw)
-
-					// save to firestore
-					if err := firestore.SaveSection(context.Background(), cleanSec); err != nil {
-						log.Printf("Error saving section %s: %v", cleanSec.ID, err)
-					} else {
-						fmt.Printf("   > Saved %s-%s (%s)\n", cleanSec.CourseID, cleanSec.Section, cleanSec.ID)
-					}
-				}(rawSec)
-			}
-
-			// pagination: increment offset
-			offset += maxSize
-			if offset >= response.TotalCount {
-				break
-			}
-
-			// no classes found, skip to next subject
-			if response.TotalCount == 0 {
-				fmt.Printf("   > No classes found for %s. Skipping.\n", subj)
-				break
-			}
-
-			// this is just to be polite
-			// and not get rate limited by the server lol
-			time.Sleep(500 * time.Millisecond)
+	if *out != "" {
+		if err := scraper.Export(context.Background(), store, *out, *format); err != nil {
+			closeStore()
+			log.Fatalf("Failed to write %s: %v", *out, err)
 		}
-		fmt.Printf("sleeping 2s before next subject\n")
-		time.Sleep(2 * time.Second)
+		log.Printf("wrote %s files to %s", *format, *out)
 	}
-	// wait for all goroutines to finish
-	wg.Wait()
-	fmt.Println("== DONE == all subjects processed.")
-}
-
-// clears the search criteria in the session
-func resetSearch(client *http.Client, token string) {
-	resetURL := fmt.Sprintf("%s/ssb/classSearch/resetDataForm", BaseURL)
-	req, _ := http.NewRequest("POST", resetURL, nil)
-	setHeaders(req, token)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Warning: Failed to reset search: %v", err)
-	} else {
-		resp.Body.Close()
+	// a partial run still exits non-zero so cron notices
+	if run.Status != types.ScrapeSucceeded {
+		closeStore()
+		os.Exit(1)
 	}
 }
 
-// set the headers
-func setHeaders(req *http.Request, token string) {
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
-	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-
-	if token != "" {
-		req.Header.Set("X-Synchronizer-Token", token)
+// where the run writes
+// -dry-run: a memory copy of the configured storage, so the change log is against real data
+// -out: an empty memory store, storage isn't touched at all
+// otherwise: the configured storage
+func openStore(ctx context.Context, out string, dryRun bool) (storage.Store, func()) {
+	if out != "" && !dryRun {
+		return memory.New().Store(), func() {}
 	}
-}
 
-// parsing time: 1330 -> 13 + 30
-// return 0 if nil or invalid
-func parseTimeStr(t *string) int {
-	if t == nil {
-		return 0
+	store, closeStore, err := backend.Open()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	val := *t
-	if len(val) < 4 {
-		return 0
+	if !dryRun {
+		return store, closeStore
 	}
-	// "1330" -> 13, 30
-	hh, _ := strconv.Atoi(val[:2])
-	mm, _ := strconv.Atoi(val[2:])
-	return (hh * 60) + mm
-}
 
-// safely dereference string pointer
-func getStr(s *string) string {
-	if s == nil {
-		return ""
+	// reads every course and section once, nothing is written back
+	defer closeStore()
+	snapshot, err := scraper.Snapshot(ctx, store)
+	if err != nil {
+		closeStore()
+		log.Fatalf("Failed to copy storage for the dry run: %v", err)
 	}
-	return *s
+	return snapshot.Store(), func() {}
 }
 
-// parse into section type
-func parseBannerSection(raw types.BannerSection) types.Section {
-	sec := types.Section{
-		ID:       raw.CRN,                        // CRN as the unique ID
-		CourseID: raw.Subject + raw.CourseNumber, // ex) CS100
-		Section:  raw.SequenceNumber,
-		// first professor if available
-		Professor: "TBA",
-	}
-
-	if len(raw.Faculty) > 0 {
-		sec.Professor = raw.Faculty[0].DisplayName
+// the dry run's change log, one line per change
+func printChanges(store storage.Store, run *types.ScrapeRun) {
+	changes, err := store.ScrapeRuns.ListScrapeChanges(context.Background(), run.ID)
+	if err != nil {
+		log.Printf("Failed to read the change log: %v", err)
+		return
 	}
 
-	// parse meetings
-	for _, mf := range raw.MeetingsFaculty {
-		mt := mf.MeetingTime
-
-		if mt.BeginTime == nil || mt.EndTime == nil {
-			continue
-		}
-
-		// converting 1000 -> 600 minutes
-		startMin := parseTimeStr(mt.BeginTime)
-		endMin := parseTimeStr(mt.EndTime)
-
-		// handle potential null location
-		loc := getStr(mt.Building) + " " + getStr(mt.Room)
-		if strings.TrimSpace(loc) == "" {
-			loc = "Online / TBA"
-		}
-
-		// banner stores days as booleans
-		// need to create a meeting for EACH true day
-		daysMap := map[int]bool{
-			0: mt.Sunday, 1: mt.Monday, 2: mt.Tuesday,
-			3: mt.Wednesday, 4: mt.Thursday, 5: mt.Friday, 6: mt.Saturday,
-		}
-
-		for dayCode, isActive := range daysMap {
-			if isActive {
-				sec.Meetings = append(sec.Meetings, types.Meeting{
-					Day:       dayCode,
-					StartTime: startMin,
-					EndTime:   endMin,
-					Location:  loc,
-				})
-			}
+	fmt.Printf("\n== DRY RUN == %d added, %d changed, %d removed, %d unchanged (nothing was written)\n",
+		run.Added, run.Changed, run.Removed, run.Unchanged)
+	for _, c := range changes {
+		line := fmt.Sprintf("%-14s %s-%s (%s) %s", c.Kind, c.CourseID, c.Section, c.SectionID, c.Term)
+		if c.Before != "" || c.After != "" {
+			line += fmt.Sprintf(": %s -> %s", c.Before, c.After)
 		}
+		fmt.Println(line)
 	}
-	return sec
 }
 
-// fetch all subjects from banner
-func GetSubjects(client *http.Client, token string) ([]string, error) {
-	fmt.Println("== 0 == Fetching Subject List...")
-
-	// fetch all subjects (max=500 should cover it)
-	apiURL := fmt.Sprintf(
-		"%s/ssb/classSearch/get_subject?searchTerm=&term=%s&offset=1&max=500",
-		BaseURL, Term,
-	)
-
-	req, _ := http.NewRequest("GET", apiURL, nil)
-	setHeaders(req, token)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// list back into flag form, empty is the keyword
+func listFlag(list []string, keyword string) string {
+	if len(list) == 0 {
+		return keyword
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
+	return strings.Join(list, ",")
+}
 
-	var subjects []types.BannerSubject
-	if err := json.Unmarshal(body, &subjects); err != nil {
-		return nil, err
+func printTerms(ctx context.Context, opts scraper.Options) {
+	terms, err := scraper.Terms(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	var codes []string
-	for _, s := range subjects {
-		codes = append(codes, s.Code)
+	for _, t := range terms {
+		fmt.Printf("%s  %s\n", t.Code, t.Description)
 	}
-
-	fmt.Printf("   > Found %d active subjects.\n", len(codes))
-	return codes, nil
 }
@@ -0,0 +1,173 @@
+package api
+
+// handlers for admin only operations
+// 1. reloading the course cache without restarting the server
+// 2. catalog and user stats
+// 3. triggering and inspecting scraper runs
+// all of these sit behind auth.RequireAdmin in main.go
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/auth"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/catalog"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/scraper"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/admin/catalog/reload
+// re-reads every course from storage and swaps the search cache
+func (h *Handler) ReloadCatalog(c *gin.Context) {
+	if err := catalog.LoadCache(c.Request.Context(), h.store.Courses); err != nil {
+		log.Printf("admin: catalog reload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "reloaded", "catalog": catalog.CacheStats()})
+}
+
+// GET /api/admin/stats
+func (h *Handler) GetStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// counts are aggregation queries on firestore, so this is cheap even with a big catalog
+	counters := map[string]func(context.Context) (int64, error){
+		"courses":  h.store.Courses.CountCourses,
+		"sections": h.store.Sections.CountSections,
+		"users":    h.store.Users.CountUsers,
+	}
+
+	counts := gin.H{}
+	for name, count := range counters {
+		n, err := count(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		counts[name] = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"catalog":        catalog.CacheStats(),
+		"counts":         counts,
+		"scrape_running": scraper.Running(),
+	})
+}
+
+// POST /api/admin/scraper/runs
+// optional input: { "terms": "202610,202620" | "auto", "subjects": "CS,MATH" | "all" }
+// a missing field falls back to SCRAPER_TERMS / SCRAPER_SUBJECTS
+// kicks off a scrape in the background, poll GET /api/admin/scraper/runs/:runID for progress
+func (h *Handler) TriggerScrape(c *gin.Context) {
+	var req struct {
+		Terms    *string `json:"terms"`
+		Subjects *string `json:"subjects"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+	}
+
+	opts := scraper.OptionsFromEnv()
+	if req.Terms != nil {
+		opts.Terms = scraper.ParseOptions(*req.Terms, "").Terms
+	}
+	if req.Subjects != nil {
+		opts.Subjects = scraper.ParseOptions("", *req.Subjects).Subjects
+	}
+
+	run, err := scraper.Start(h.store, "admin:"+auth.UserID(c), opts)
+	if errors.Is(err, scraper.ErrAlreadyRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GET /api/admin/scraper/terms
+// asks banner which terms it has, to pick what to scrape next
+func (h *Handler) GetBannerTerms(c *gin.Context) {
+	terms, err := scraper.Terms(c.Request.Context(), scraper.OptionsFromEnv())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, terms)
+}
+
+// GET /api/admin/scraper/runs?limit=20
+func (h *Handler) GetScrapeRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	runs, err := h.store.ScrapeRuns.ListScrapeRuns(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// GET /api/admin/scraper/runs/:runID
+func (h *Handler) GetScrapeRun(c *gin.Context) {
+	run, err := h.store.ScrapeRuns.GetScrapeRun(c.Request.Context(), c.Param("runID"))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// GET /api/admin/scraper/runs/:runID/changes?kind=time_changed
+// the run's change log, optionally only one kind of change
+func (h *Handler) GetScrapeChanges(c *gin.Context) {
+	ctx := c.Request.Context()
+	runID := c.Param("runID")
+
+	// an unknown run is a 404, not an empty log
+	if _, err := h.store.ScrapeRuns.GetScrapeRun(ctx, runID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "run not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	changes, err := h.store.ScrapeRuns.ListScrapeChanges(ctx, runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if kind := c.Query("kind"); kind != "" {
+		changes = slices.DeleteFunc(changes, func(ch types.SectionChange) bool { return ch.Kind != kind })
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/catalog"
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 	"github.com/gin-gonic/gin"
 )
 
 // GET /api/search?q=CS110
-func HandleSearchCourses(c *gin.Context) {
+func (h *Handler) HandleSearchCourses(c *gin.Context) {
 	query := c.Query("q")
 
 	// only search if query length >= 2
@@ -22,18 +25,43 @@ func HandleSearchCourses(c *gin.Context) {
 	c.JSON(http.StatusOK, results)
 }
 
-func HandleGetSections(c *gin.Context) {
+func (h *Handler) HandleGetSections(c *gin.Context) {
 	// get courseID from query parameter
 	// /api/sections?courseID=CS110
+	// or by instructor, an email or a name: /api/sections?instructor=jsmith@gmu.edu&term=202610
 	courseID := c.Query("courseID")
 
+	if instructor := strings.TrimSpace(c.Query("instructor")); instructor != "" && courseID == "" {
+		h.getSectionsByInstructor(c, instructor, c.Query("term"))
+		return
+	}
+
 	if courseID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "courseID is required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "courseID or instructor is required"})
+		return
+	}
+
+	// pass the context from gin to storage
+	sections, err := h.store.Sections.GetSectionsForCourse(c.Request.Context(), courseID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "course not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sections)
+}
+
+func (h *Handler) getSectionsByInstructor(c *gin.Context, instructor, term string) {
+	id := types.InstructorID(instructor, "")
+	if strings.Contains(instructor, "@") {
+		id = types.InstructorID("", instructor)
+	}
 
-	// pass the context from gin to firestore
-	sections, err := firestore.GetSectionsForCourse(c.Request.Context(), courseID)
+	sections, err := h.store.Sections.ListSectionsByInstructor(c.Request.Context(), id, term)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *memory.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := memory.New()
+	db.Seed(memory.Fixture{
+		Courses: []types.Course{
+			{ID: "CS110", Department: "CS", Code: "110", Title: "Essentials", SectionIDs: []string{"10001", "99999"}},
+			{ID: "CS211", Department: "CS", Code: "211", Title: "OOP"},
+		},
+		Sections: []types.Section{
+			{ID: "10001", CourseID: "CS110", Section: "001", Professor: "Doe, Jane"},
+			{ID: "10002", CourseID: "CS110", Section: "002", Professor: "Smith, John", Instructors: []types.Instructor{
+				{ID: "jsmith@gmu.edu", Name: "Smith, John", Email: "jsmith@gmu.edu", Primary: true},
+			}},
+		},
+	})
+
+	h := NewHandler(db.Store())
+	r := gin.New()
+	r.GET("/api/sections", h.HandleGetSections)
+	r.POST("/api/users/:userID/schedules", h.SaveCurrentSchedule)
+	r.GET("/api/users/:userID/schedules", h.GetSavedCurrentSchedules)
+	r.GET("/api/users/:userID/schedules/:scheduleID/versions", h.GetScheduleVersions)
+	r.GET("/api/users/:userID/schedules/:scheduleID/versions/:version", h.GetScheduleVersion)
+	r.POST("/api/users/:userID/schedules/:scheduleID/versions/:version/restore", h.RestoreScheduleVersion)
+	return r, db
+}
+
+func TestHandleGetSections(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{"missing course id", "", http.StatusBadRequest, 0},
+		{"unknown course", "?courseID=NOPE100", http.StatusNotFound, 0},
+		{"dangling section id is skipped", "?courseID=CS110", http.StatusOK, 1},
+		{"course without sections", "?courseID=CS211", http.StatusOK, 0},
+		{"by instructor name", "?instructor=doe,%20jane", http.StatusOK, 1},
+		{"by name of an instructor with an email", "?instructor=Smith,%20John", http.StatusOK, 1},
+		{"by instructor email", "?instructor=JSmith@gmu.edu", http.StatusOK, 1},
+		{"by instructor in another term", "?instructor=Doe,%20Jane&term=202670", http.StatusOK, 0},
+		{"unknown instructor", "?instructor=nobody@gmu.edu", http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sections"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var sections []types.Section
+			if err := json.Unmarshal(w.Body.Bytes(), &sections); err != nil {
+				t.Fatal(err)
+			}
+			if len(sections) != tt.wantCount {
+				t.Errorf("got %d sections, want %d", len(sections), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestSaveAndGetSchedules(t *testing.T) {
+	r, db := newTestRouter(t)
+
+	body := `{"id":"current","name":"Current Semester","sections":[{"id":"10001","course_id":"CS110"}]}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/users/u1/schedules", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("save status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	saved, err := db.ListSchedules(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].ID != "current" || len(saved[0].Sections) != 1 {
+		t.Fatalf("unexpected stored schedules: %+v", saved)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/u1/schedules", nil))
+
+	var schedules []types.Schedule
+	if err := json.Unmarshal(w.Body.Bytes(), &schedules); err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].Name != "Current Semester" {
+		t.Errorf("unexpected schedules: %+v", schedules)
+	}
+}
+
+// the scheduler page autosaves "current" on every change, sending back the revision it last got
+func TestAutosaveCurrentSchedule(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	save := func(sections string, revision int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"id":"current","name":"Current Semester","sections":%s,"revision":%d}`, sections, revision)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/users/u1/schedules", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	var resp struct {
+		Revision int            `json:"revision"`
+		Current  types.Schedule `json:"current"`
+	}
+
+	// nothing saved yet, the page starts from revision 0
+	w := save(`[{"id":"10001"}]`, 0)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("first save: %d %s", w.Code, w.Body.String())
+	}
+
+	// the second change goes out with the revision the first save returned
+	w = save(`[]`, resp.Revision)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Revision != 2 {
+		t.Fatalf("second save: %d %s", w.Code, w.Body.String())
+	}
+
+	// a reload of the page picks the revision up from the list
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/u1/schedules", nil))
+	var schedules []types.Schedule
+	if err := json.Unmarshal(w.Body.Bytes(), &schedules); err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].Revision != 2 {
+		t.Fatalf("listed schedules: %+v", schedules)
+	}
+
+	// a tab that never saw revision 2 gets what's stored instead
+	w = save(`[{"id":"10001"}]`, 1)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusConflict {
+		t.Fatalf("stale save: %d %s", w.Code, w.Body.String())
+	}
+	if resp.Current.Revision != 2 || len(resp.Current.Sections) != 0 {
+		t.Fatalf("conflict should carry the stored schedule, got %+v", resp.Current)
+	}
+}
+
+func TestScheduleHistoryRestore(t *testing.T) {
+	r, db := newTestRouter(t)
+
+	save := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/users/u1/schedules", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, name := range []string{"Plan A", "Plan A tweaked"} {
+		body := fmt.Sprintf(`{"id":"plan-a","name":%q,"revision":%d}`, name, i)
+		if w := save(body); w.Code != http.StatusOK {
+			t.Fatalf("save status = %d, body %s", w.Code, w.Body.String())
+		}
+	}
+
+	// a tab still on revision 1 gets the stored schedule back instead of overwriting it
+	w := save(`{"id":"plan-a","name":"stale tab","revision":1}`)
+	var conflict struct {
+		Current types.Schedule `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusConflict || conflict.Current.Name != "Plan A tweaked" || conflict.Current.Revision != 2 {
+		t.Fatalf("stale save: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/u1/schedules/plan-a/versions", nil))
+	var versions []types.ScheduleVersion
+	if err := json.Unmarshal(w.Body.Bytes(), &versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Schedule.Name != "Plan A" {
+		t.Fatalf("unexpected versions: %+v", versions)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/u1/schedules/plan-a/versions/9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing version status = %d, want 404", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/users/u1/schedules/plan-a/versions/1/restore", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	saved, err := db.ListSchedules(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Name != "Plan A" {
+		t.Fatalf("restore didn't bring back version 1: %+v", saved)
+	}
+
+	// the restore is a save of its own, so it can be undone as well
+	history, err := db.ListScheduleVersions(context.Background(), "u1", "plan-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Version != 3 {
+		t.Fatalf("unexpected history after restore: %+v", history)
+	}
+}
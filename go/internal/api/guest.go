@@ -0,0 +1,41 @@
+package api
+
+// handlers for guest mode schedules
+// same shape as the signed in schedule handlers, but keyed by the guest session
+// everything here gets merged into the real account on sign in (see auth.CallbackHandler)
+
+import (
+	"net/http"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/auth"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/guest/schedules
+func (h *Handler) SaveGuestSchedule(c *gin.Context) {
+	var schedule types.Schedule
+	if err := c.BindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	id, err := h.store.Guests.SaveGuestSchedule(c.Request.Context(), auth.GuestID(c), schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "saved", "id": id})
+}
+
+// GET /api/guest/schedules
+func (h *Handler) GetGuestSchedules(c *gin.Context) {
+	schedules, err := h.store.Guests.ListGuestSchedules(c.Request.Context(), auth.GuestID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+)
+
+// holds the storage every API handler reads and writes through
+// handlers are methods on this so tests can hand in a fake store
+type Handler struct {
+	store storage.Store
+}
+
+func NewHandler(store storage.Store) *Handler {
+	return &Handler{store: store}
+}
@@ -10,10 +10,12 @@ package api
 // that is done in the scheduler module
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/scheduler"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 	"github.com/gin-gonic/gin"
 )
@@ -22,7 +24,7 @@ import (
 // --- current schedule related handlers ---
 
 // save schedule
-func SaveCurrentSchedule(c *gin.Context) {
+func (h *Handler) SaveCurrentSchedule(c *gin.Context) {
 	// /users/{userID}/schedules
 	userID := c.Param("userID")
 
@@ -32,20 +34,40 @@ func SaveCurrentSchedule(c *gin.Context) {
 		return
 	}
 
-	err := firestore.SaveUserSchedule(c.Request.Context(), userID, schedule)
+	// schedule.Revision is the revision the client loaded, 0 for a new schedule
+	saved, err := h.store.Schedules.SaveSchedule(c.Request.Context(), userID, schedule)
+	if respondConflict(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "saved", "id": schedule.ID})
+	// the client keeps the new revision for its next save
+	c.JSON(http.StatusOK, gin.H{"status": "saved", "id": saved.ID, "revision": saved.Revision, "updated_at": saved.UpdatedAt})
+}
+
+// 409 with the stored schedule when a save was based on an old revision
+// (another tab or device saved in between), so the client can reload or merge
+func respondConflict(c *gin.Context, err error) bool {
+	var conflict *storage.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Schedule was changed since it was loaded",
+		"current": conflict.Current,
+	})
+	return true
 }
 
 // get saved schedules
-func GetSavedCurrentSchedules(c *gin.Context) {
+func (h *Handler) GetSavedCurrentSchedules(c *gin.Context) {
 	userID := c.Param("userID")
 
-	schedules, err := firestore.GetUserSchedules(c.Request.Context(), userID)
+	schedules, err := h.store.Schedules.ListSchedules(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -54,14 +76,109 @@ func GetSavedCurrentSchedules(c *gin.Context) {
 	c.JSON(http.StatusOK, schedules)
 }
 
+//
+// --- schedule history handlers ---
+// every save is kept as a version (bounded, see storage.ScheduleHistoryLimit)
+// restoring saves the old version again, so the restore itself can be undone too
+
+// GET /api/users/:userID/schedules/:scheduleID/versions
+// newest first, the first entry is what's currently saved
+func (h *Handler) GetScheduleVersions(c *gin.Context) {
+	versions, err := h.store.Schedules.ListScheduleVersions(c.Request.Context(), c.Param("userID"), c.Param("scheduleID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// GET /api/users/:userID/schedules/:scheduleID/versions/:version
+func (h *Handler) GetScheduleVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	v, err := h.store.Schedules.GetScheduleVersion(c.Request.Context(), c.Param("userID"), c.Param("scheduleID"), version)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+// POST /api/users/:userID/schedules/:scheduleID/versions/:version/restore
+func (h *Handler) RestoreScheduleVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.Param("userID")
+	scheduleID := c.Param("scheduleID")
+
+	v, err := h.store.Schedules.GetScheduleVersion(ctx, userID, scheduleID, version)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// restoring is an explicit "go back to this", so it's based on whatever is stored now
+	current, err := h.store.Schedules.GetSchedule(ctx, userID, scheduleID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := v.Schedule
+	schedule.ID = scheduleID
+	schedule.UserID = userID
+	schedule.Revision = 0
+	if current != nil {
+		schedule.Revision = current.Revision
+	}
+
+	saved, err := h.store.Schedules.SaveSchedule(ctx, userID, schedule)
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "restored", "restored_from": version, "schedule": saved})
+}
+
+// parses :version, responds 400 itself when it's not a positive number
+func versionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return 0, false
+	}
+	return version, true
+}
+
 //
 // --- schedule generation related handlers ---
 
 // POST /api/generate
 // input: { "courseIds": ["CS101", "MATH200"] }
 // input should be course IDs not CRN because we want to generate all possible sections
-// output: Returns the generated schedules (and saves them to DB)
-func GenerateSchedule(c *gin.Context) {
+// output: Returns the generated schedules, they aren't saved (see storage.ExportUser)
+func (h *Handler) GenerateSchedule(c *gin.Context) {
 	var req types.GenerateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
@@ -75,10 +192,10 @@ func GenerateSchedule(c *gin.Context) {
 		return
 	}
 
-	// 1. fetch section data from firestore
+	// 1. fetch section data from storage
 	// 2. run backtracking algorithm to generate valid schedules
 	// 3. save results to schedules collection
-	generatedSchedules, err := scheduler.Run(c.Request.Context(), req.CourseIDs, req.UserID)
+	generatedSchedules, err := scheduler.Run(c.Request.Context(), h.store.Sections, req.CourseIDs, req.UserID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requires the caller to have the admin role
+// must run after RequireUser
+func (a *Auth) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := a.store.Users.GetUser(c.Request.Context(), UserID(c))
+		if err != nil {
+			log.Printf("Storage error: %v", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+
+		if !user.IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// checks the email against ADMIN_EMAILS (comma separated)
+// these accounts get promoted to admin on sign in
+func isAdminEmail(email string) bool {
+	if email == "" {
+		return false
+	}
+
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if strings.EqualFold(strings.TrimSpace(e), email) {
+			return true
+		}
+	}
+	return false
+}
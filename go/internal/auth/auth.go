@@ -28,7 +28,7 @@ import (
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/google"
 
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 )
 
@@ -46,8 +46,13 @@ func init() {
 	gob.Register(goth.User{})
 }
 
+// auth handlers that need to read or write users
+type Auth struct {
+	store storage.Store
+}
+
 // init authentication providers and session store
-func NewAuth() {
+func NewAuth(repos storage.Store) *Auth {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal(".env file failed to load")
@@ -68,6 +73,8 @@ func NewAuth() {
 	gothic.Store = store
 
 	goth.UseProviders(google.New(googleClientId, googleClientSecret, googleCallbackURL))
+
+	return &Auth{store: repos}
 }
 
 // signin handler
@@ -84,7 +91,7 @@ func SignInWithProvider(c *gin.Context) {
 }
 
 // callback handler
-func CallbackHandler(c *gin.Context) {
+func (a *Auth) CallbackHandler(c *gin.Context) {
 	provider := c.Param("provider")
 
 	// SA1029: gothic library requires the key to be the string "provider"
@@ -97,17 +104,36 @@ func CallbackHandler(c *gin.Context) {
 		return
 	}
 
-	// save user to Firestore
-	firestore.SaveUserToFirestore(c.Request.Context(), types.User{
+	// save user to storage
+	profile := types.User{
 		ID:        user.UserID,
 		Name:      user.Name,
 		Email:     user.Email,
 		AvatarURL: user.AvatarURL,
-	})
+	}
+
+	// bootstrap admins from env, other roles are managed in storage directly.
+	// this only promotes, an empty role leaves the stored one alone, so taking an email
+	// off the list doesn't demote anyone (admins can be granted in storage too)
+	if isAdminEmail(user.Email) {
+		profile.Role = types.RoleAdmin
+	}
+
+	a.store.Users.SaveUser(c.Request.Context(), profile)
 
 	// get/create session for the user
 	session, _ := gothic.Store.Get(c.Request, "user-session")
 
+	// carry over anything built in guest mode before signing in
+	// a failed merge shouldn't block the login, the guest data stays put for a retry
+	if guestID, _ := session.Values[guestSessionKey].(string); guestID != "" {
+		if err := a.mergeGuestSchedules(c.Request.Context(), guestID, user.UserID); err != nil {
+			log.Printf("auth: failed to merge guest %s into user %s: %v", guestID, user.UserID, err)
+		} else {
+			delete(session.Values, guestSessionKey)
+		}
+	}
+
 	// store ONLY the user ID in the session
 	session.Values["user_id"] = user.UserID
 
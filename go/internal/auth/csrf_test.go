@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
+)
+
+// fresh cookie store for the test, gothic keeps it in a global
+func useTestSessions(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	prev := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("test-session-key"))
+	t.Cleanup(func() { gothic.Store = prev })
+}
+
+// a user-session cookie holding values, as the browser would send it back
+func sessionCookie(t *testing.T, values map[string]interface{}) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	session, _ := gothic.Store.Get(req, "user-session")
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	if err := session.Save(req, w); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("want one session cookie, got %d", len(cookies))
+	}
+	return cookies[0]
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	useTestSessions(t)
+	t.Setenv("FRONTEND_URL", "http://localhost:3000")
+
+	r := gin.New()
+	r.Use(CSRFMiddleware())
+	handled := false
+	ok := func(c *gin.Context) {
+		handled = true
+		c.Status(http.StatusNoContent)
+	}
+	r.POST("/thing", ok)
+	r.GET("/thing", ok)
+	r.HEAD("/thing", ok)
+	r.OPTIONS("/thing", ok)
+
+	cookie := sessionCookie(t, map[string]interface{}{csrfSessionKey: "good-token"})
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		origin   string
+		referer  string
+		noCookie bool
+		wantCode int
+	}{
+		{"matching token", http.MethodPost, "good-token", "http://localhost:3000", "", false, http.StatusNoContent},
+		{"matching token without origin", http.MethodPost, "good-token", "", "", false, http.StatusNoContent},
+		{"missing token", http.MethodPost, "", "http://localhost:3000", "", false, http.StatusForbidden},
+		{"mismatched token", http.MethodPost, "bad-token", "http://localhost:3000", "", false, http.StatusForbidden},
+		{"no session", http.MethodPost, "good-token", "", "", true, http.StatusForbidden},
+		{"foreign origin", http.MethodPost, "good-token", "https://evil.example", "", false, http.StatusForbidden},
+		{"foreign referer", http.MethodPost, "good-token", "", "https://evil.example/page", false, http.StatusForbidden},
+		{"other scheme", http.MethodPost, "good-token", "https://localhost:3000", "", false, http.StatusForbidden},
+		{"GET passes", http.MethodGet, "", "https://evil.example", "", true, http.StatusNoContent},
+		{"HEAD passes", http.MethodHead, "", "https://evil.example", "", true, http.StatusNoContent},
+		{"OPTIONS passes", http.MethodOptions, "", "https://evil.example", "", true, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled = false
+			req := httptest.NewRequest(tt.method, "/thing", nil)
+			if !tt.noCookie {
+				req.AddCookie(cookie)
+			}
+			if tt.token != "" {
+				req.Header.Set(CSRFHeader, tt.token)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if handled != (tt.wantCode == http.StatusNoContent) {
+				t.Fatalf("handler ran = %v", handled)
+			}
+		})
+	}
+}
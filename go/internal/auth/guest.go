@@ -0,0 +1,127 @@
+package auth
+
+// guest mode
+// visitors can build schedules before signing in, keyed by a random guest ID
+// kept in the same gothic session cookie a real login would use.
+// on sign in, CallbackHandler folds the guest schedules into the user's account
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth/gothic"
+)
+
+const (
+	ContextGuestID  string = "guest_id"
+	guestSessionKey string = "guest_id"
+)
+
+// gives the caller a guest ID, creating one on first visit
+// signed in users are pointed at their real schedules instead
+func RequireGuest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, _ := gothic.Store.Get(c.Request, "user-session")
+
+		if id, _ := session.Values["user_id"].(string); id != "" {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "already signed in, use /api/users/:userID/schedules"})
+			return
+		}
+
+		guestID, _ := session.Values[guestSessionKey].(string)
+		if guestID == "" {
+			var err error
+			guestID, err = randomHex(16)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to start guest session"})
+				return
+			}
+
+			session.Values[guestSessionKey] = guestID
+			if err := session.Save(c.Request, c.Writer); err != nil {
+				log.Println("guest: session save error:", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to start guest session"})
+				return
+			}
+		}
+
+		c.Set(ContextGuestID, guestID)
+		c.Next()
+	}
+}
+
+// returns the guest ID set by RequireGuest
+func GuestID(c *gin.Context) string {
+	return c.GetString(ContextGuestID)
+}
+
+// moves every guest schedule into users/{userID}/schedules
+// duplicate names get a numeric suffix: "Plan A" -> "Plan A (2)"
+// duplicate IDs get a fresh ID so nothing saved on the account is overwritten
+// each guest schedule is deleted as soon as it's on the account, so a merge that fails
+// halfway picks up where it stopped on the next sign in instead of duplicating what made it over
+func (a *Auth) mergeGuestSchedules(ctx context.Context, guestID, userID string) error {
+	guestSchedules, err := a.store.Guests.ListGuestSchedules(ctx, guestID)
+	if err != nil {
+		return err
+	}
+	if len(guestSchedules) == 0 {
+		return a.store.Guests.DeleteGuest(ctx, guestID)
+	}
+
+	existing, err := a.store.Schedules.ListSchedules(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	takenIDs := make(map[string]bool)
+	takenNames := make(map[string]bool)
+	for _, s := range existing {
+		takenIDs[s.ID] = true
+		takenNames[s.Name] = true
+	}
+
+	for _, s := range guestSchedules {
+		guestScheduleID := s.ID
+		s.UserID = userID
+		s.Name = uniqueName(s.Name, takenNames)
+
+		// an empty ID makes SaveSchedule generate a new one
+		if takenIDs[s.ID] {
+			s.ID = ""
+		}
+		// lands on the account as a brand new schedule
+		s.Revision = 0
+
+		saved, err := a.store.Schedules.SaveSchedule(ctx, userID, s)
+		if err != nil {
+			return fmt.Errorf("failed to merge guest schedule %q: %w", s.Name, err)
+		}
+		takenIDs[saved.ID] = true
+		takenNames[saved.Name] = true
+
+		// NOTE: not atomic with the save, if this fails the schedule is merged again next time
+		if err := a.store.Guests.DeleteGuestSchedule(ctx, guestID, guestScheduleID); err != nil {
+			return fmt.Errorf("failed to clear merged guest schedule %q: %w", s.Name, err)
+		}
+	}
+
+	// everything made it over, drop what's left of the guest
+	return a.store.Guests.DeleteGuest(ctx, guestID)
+}
+
+// appends " (2)", " (3)", ... until the name is free
+func uniqueName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
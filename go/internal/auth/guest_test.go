@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func TestUniqueName(t *testing.T) {
+	taken := map[string]bool{"Plan A": true, "Plan A (2)": true, "Plan B (2)": true}
+
+	tests := []struct{ name, want string }{
+		{"Plan C", "Plan C"},
+		{"Plan A", "Plan A (3)"},
+		{"Plan B", "Plan B"},
+		{"Plan B (2)", "Plan B (2) (2)"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := uniqueName(tt.name, taken); got != tt.want {
+			t.Errorf("uniqueName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// schedules that fail to save after a number of successes
+type flakySchedules struct {
+	storage.ScheduleRepository
+	failAfter int
+}
+
+func (f *flakySchedules) SaveSchedule(ctx context.Context, userID string, s types.Schedule) (*types.Schedule, error) {
+	if f.failAfter == 0 {
+		return nil, errors.New("storage is down")
+	}
+	f.failAfter--
+	return f.ScheduleRepository.SaveSchedule(ctx, userID, s)
+}
+
+// names of the user's schedules, sorted
+func scheduleNames(t *testing.T, db *memory.DB, userID string) []string {
+	t.Helper()
+	schedules, err := db.ListSchedules(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range schedules {
+		names = append(names, s.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func seedMerge(t *testing.T) *memory.DB {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.New()
+
+	// on the account already
+	if _, err := db.SaveSchedule(ctx, "u1", types.Schedule{ID: "s1", Name: "Plan A"}); err != nil {
+		t.Fatal(err)
+	}
+	// same ID as the account's, same name, and a plain new one
+	for _, s := range []types.Schedule{{ID: "s1", Name: "Fall"}, {ID: "g2", Name: "Plan A"}, {ID: "g3", Name: "Spring"}} {
+		if _, err := db.SaveGuestSchedule(ctx, "guest", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestMergeGuestSchedules(t *testing.T) {
+	ctx := context.Background()
+	db := seedMerge(t)
+	a := &Auth{store: db.Store()}
+
+	if err := a.mergeGuestSchedules(ctx, "guest", "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(scheduleNames(t, db, "u1")); got != "[Fall Plan A Plan A (2) Spring]" {
+		t.Fatalf("schedules after merge: %s", got)
+	}
+	// the account's s1 wasn't overwritten by the guest's s1
+	s1, err := db.GetSchedule(ctx, "u1", "s1")
+	if err != nil || s1.Name != "Plan A" {
+		t.Fatalf("s1 = %+v, %v", s1, err)
+	}
+	if left, _ := db.ListGuestSchedules(ctx, "guest"); len(left) != 0 {
+		t.Fatalf("guest data kept after merge: %+v", left)
+	}
+}
+
+func TestMergeGuestSchedulesRetry(t *testing.T) {
+	ctx := context.Background()
+	db := seedMerge(t)
+	store := db.Store()
+	store.Schedules = &flakySchedules{ScheduleRepository: db, failAfter: 1}
+	a := &Auth{store: store}
+
+	if err := a.mergeGuestSchedules(ctx, "guest", "u1"); err == nil {
+		t.Fatal("want the merge to fail halfway")
+	}
+	if left, _ := db.ListGuestSchedules(ctx, "guest"); len(left) != 2 {
+		t.Fatalf("want the merged schedule gone from the guest, %d left", len(left))
+	}
+
+	// next sign in, storage is back: nothing is merged twice
+	a.store = db.Store()
+	if err := a.mergeGuestSchedules(ctx, "guest", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(scheduleNames(t, db, "u1")); got != "[Fall Plan A Plan A (2) Spring]" {
+		t.Fatalf("schedules after retry: %s", got)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+// middleware to resolve who is calling
+// accepts either the gothic session cookie (browser)
+// or a personal API token in the Authorization header (scripts, notebooks)
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth/gothic"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// keys set on the gin context by RequireUser
+const (
+	ContextUserID   string = "user_id"
+	ContextReadOnly string = "read_only"
+	ContextViaToken string = "via_token"
+)
+
+// requires a logged in user, via session cookie or bearer token
+// if the route has a :userID param it must match the caller
+func (a *Auth) RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID string
+
+		if raw, ok := bearerToken(c.Request); ok {
+			// a bearer header means token auth ONLY, never fall back to the cookie
+			// the CSRF middleware skips these requests based on the same header
+			token, err := a.verifyAPIToken(c.Request.Context(), raw)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API token"})
+				return
+			}
+
+			// read-only tokens can look but not touch
+			if token.ReadOnly && !isSafeMethod(c.Request.Method) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API token is read-only"})
+				return
+			}
+
+			userID = token.UserID
+			c.Set(ContextReadOnly, token.ReadOnly)
+			c.Set(ContextViaToken, true)
+
+			// best effort, don't fail the request over bookkeeping
+			if err := a.store.Tokens.TouchToken(c.Request.Context(), token.ID); err != nil {
+				log.Printf("auth: failed to update token %s last use: %v", token.ID, err)
+			}
+		} else {
+			session, err := gothic.Store.Get(c.Request, "user-session")
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+				return
+			}
+
+			id, ok := session.Values["user_id"].(string)
+			if !ok || id == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+				return
+			}
+
+			// cookie sessions can't be revoked server side, so make sure the account still exists
+			// this is what logs out every other browser after an account deletion
+			if _, err := a.store.Users.GetUser(c.Request.Context(), id); errors.Is(err, storage.ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+				return
+			} else if err != nil {
+				log.Printf("auth: failed to verify session user %s: %v", id, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify session"})
+				return
+			}
+			userID = id
+		}
+
+		// users can only touch their own data
+		if param := c.Param("userID"); param != "" && param != userID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		c.Set(ContextUserID, userID)
+		c.Next()
+	}
+}
+
+// returns the user ID set by RequireUser
+func UserID(c *gin.Context) string {
+	return c.GetString(ContextUserID)
+}
+
+// pulls the token out of "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// looks up the token by its ID and checks the secret against the stored hash
+func (a *Auth) verifyAPIToken(ctx context.Context, raw string) (*types.APIToken, error) {
+	id, secret, err := parseAPIToken(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.store.Tokens.GetToken(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hashMatches(token.Hash, secret) {
+		return nil, errors.New("token secret mismatch")
+	}
+	return token, nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
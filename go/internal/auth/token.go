@@ -0,0 +1,138 @@
+package auth
+
+// personal API tokens so people can script against the API
+// without going through the google oauth browser flow
+//
+// token format: dmt_<id>_<secret>
+// the id is used to look up the stored record, the secret is only ever stored hashed
+// secrets are 32 random bytes so a plain sha256 is enough (no bcrypt needed)
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+const tokenPrefix string = "dmt"
+
+type createTokenRequest struct {
+	Name     string `json:"name"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// POST /api/tokens
+// input: { "name": "my notebook", "read_only": true }
+// output: the token record plus the plain token, which is never shown again
+func (a *Auth) CreateAPIToken(c *gin.Context) {
+	// a leaked token shouldn't be able to mint more tokens
+	if c.GetBool(ContextViaToken) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API tokens can only be created from a browser session"})
+		return
+	}
+
+	var req createTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	token := types.APIToken{
+		ID:        id,
+		UserID:    UserID(c),
+		Name:      req.Name,
+		Hash:      hashSecret(secret),
+		ReadOnly:  req.ReadOnly,
+		CreatedAt: time.Now(),
+	}
+
+	if err := a.store.Tokens.SaveToken(c.Request.Context(), token); err != nil {
+		log.Printf("Storage error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":   tokenPrefix + "_" + id + "_" + secret,
+		"details": token,
+	})
+}
+
+// GET /api/tokens
+func (a *Auth) ListAPITokens(c *gin.Context) {
+	tokens, err := a.store.Tokens.ListTokens(c.Request.Context(), UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// DELETE /api/tokens/:tokenID
+func (a *Auth) RevokeAPIToken(c *gin.Context) {
+	err := a.store.Tokens.DeleteToken(c.Request.Context(), UserID(c), c.Param("tokenID"))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "revoked", "id": c.Param("tokenID")})
+}
+
+// splits dmt_<id>_<secret> into its parts
+func parseAPIToken(raw string) (id, secret string, err error) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != tokenPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", errors.New("malformed API token")
+	}
+	return parts[1], parts[2], nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashMatches(stored, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(hashSecret(secret))) == 1
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
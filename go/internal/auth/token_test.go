@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func newTokenRouter(t *testing.T) (*gin.Engine, *memory.DB) {
+	t.Helper()
+	useTestSessions(t)
+
+	db := memory.New()
+	db.Seed(memory.Fixture{Users: []types.User{{ID: "u1", Name: "Ada"}, {ID: "u2", Name: "Alan"}}})
+	a := &Auth{store: db.Store()}
+
+	r := gin.New()
+	tokens := r.Group("/api/tokens", a.RequireUser())
+	tokens.POST("", a.CreateAPIToken)
+	tokens.DELETE("/:tokenID", a.RevokeAPIToken)
+
+	users := r.Group("/api/users/:userID", a.RequireUser())
+	users.GET("/schedules", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"user": UserID(c)}) })
+	users.POST("/schedules", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"user": UserID(c)}) })
+	return r, db
+}
+
+// a token stored for userID, returned in the form callers send it
+func storeToken(t *testing.T, db *memory.DB, id, userID string, readOnly bool) string {
+	t.Helper()
+	secret := strings.Repeat("ab", 32)
+	token := types.APIToken{ID: id, UserID: userID, Name: id, Hash: hashSecret(secret), ReadOnly: readOnly, CreatedAt: time.Now()}
+	if err := db.SaveToken(t.Context(), token); err != nil {
+		t.Fatal(err)
+	}
+	return tokenPrefix + "_" + id + "_" + secret
+}
+
+func send(r *gin.Engine, method, path, bearer string, cookie *http.Cookie, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestParseAPIToken(t *testing.T) {
+	secret := strings.Repeat("ab", 32)
+	id, got, err := parseAPIToken(tokenPrefix + "_abc123_" + secret)
+	if err != nil || id != "abc123" || got != secret {
+		t.Fatalf("parse = %q, %q, %v", id, got, err)
+	}
+	if !hashMatches(hashSecret(secret), secret) {
+		t.Fatal("secret doesn't match its own hash")
+	}
+	if hashMatches(hashSecret(secret), strings.Repeat("cd", 32)) {
+		t.Fatal("other secret matches the hash")
+	}
+
+	for _, raw := range []string{"", "not-a-token", "xyz_abc_" + secret, tokenPrefix + "__" + secret, tokenPrefix + "_abc_", tokenPrefix + "_a_b_c"} {
+		if _, _, err := parseAPIToken(raw); err == nil {
+			t.Errorf("parsed %q", raw)
+		}
+	}
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	r, db := newTokenRouter(t)
+	full := storeToken(t, db, "full", "u1", false)
+	readOnly := storeToken(t, db, "ro", "u1", true)
+	revoked := storeToken(t, db, "gone", "u1", false)
+	if err := db.DeleteToken(t.Context(), "u1", "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		bearer   string
+		wantCode int
+	}{
+		{"valid token", http.MethodPost, "/api/users/u1/schedules", full, http.StatusOK},
+		{"read-only token reads", http.MethodGet, "/api/users/u1/schedules", readOnly, http.StatusOK},
+		{"read-only token writes", http.MethodPost, "/api/users/u1/schedules", readOnly, http.StatusForbidden},
+		{"someone else's data", http.MethodGet, "/api/users/u2/schedules", full, http.StatusForbidden},
+		{"malformed", http.MethodGet, "/api/users/u1/schedules", "not-a-token", http.StatusUnauthorized},
+		{"wrong prefix", http.MethodGet, "/api/users/u1/schedules", strings.Replace(full, "dmt_", "xyz_", 1), http.StatusUnauthorized},
+		{"unknown id", http.MethodGet, "/api/users/u1/schedules", tokenPrefix + "_nope_" + strings.Repeat("ab", 32), http.StatusUnauthorized},
+		{"wrong secret", http.MethodGet, "/api/users/u1/schedules", tokenPrefix + "_full_" + strings.Repeat("cd", 32), http.StatusUnauthorized},
+		{"revoked", http.MethodGet, "/api/users/u1/schedules", revoked, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(r, tt.method, tt.path, tt.bearer, nil, "")
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	// a bearer header is token auth only, a valid cookie doesn't rescue a bad token
+	cookie := sessionCookie(t, map[string]interface{}{"user_id": "u1"})
+	if w := send(r, http.MethodGet, "/api/users/u1/schedules", "dmt_bad", cookie, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token with a cookie: status = %d, want 401", w.Code)
+	}
+}
+
+func TestCreateAPIToken(t *testing.T) {
+	r, db := newTokenRouter(t)
+	cookie := sessionCookie(t, map[string]interface{}{"user_id": "u1"})
+
+	w := send(r, http.MethodPost, "/api/tokens", "", cookie, `{"name":"notebook","read_only":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d (body %s)", w.Code, w.Body.String())
+	}
+	var created struct {
+		Token   string         `json:"token"`
+		Details types.APIToken `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(w.Body.String(), hashSecret(strings.Split(created.Token, "_")[2])) {
+		t.Fatal("response leaks the stored hash")
+	}
+
+	// the new token works, read-only as asked
+	if w := send(r, http.MethodGet, "/api/users/u1/schedules", created.Token, nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("new token rejected: %d", w.Code)
+	}
+	if w := send(r, http.MethodPost, "/api/users/u1/schedules", created.Token, nil, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("new read-only token wrote: %d", w.Code)
+	}
+
+	// a token, even a full one, can't mint more tokens
+	full := storeToken(t, db, "full", "u1", false)
+	if w := send(r, http.MethodPost, "/api/tokens", full, nil, `{"name":"more"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("token created a token: status = %d (body %s)", w.Code, w.Body.String())
+	}
+
+	// revoking it locks it out
+	if w := send(r, http.MethodDelete, "/api/tokens/"+created.Details.ID, "", cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("revoke: status = %d", w.Code)
+	}
+	if w := send(r, http.MethodGet, "/api/users/u1/schedules", created.Token, nil, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token accepted: %d", w.Code)
+	}
+}
@@ -1,54 +1,87 @@
 package auth
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 
-	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
 	"github.com/gin-gonic/gin"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 )
 
-// get user profile, fetching data from firestore
-func GetUserProfile(c *gin.Context) {
-	session, err := gothic.Store.Get(c.Request, "user-session")
+// get user profile, fetching data from storage
+// expects RequireUser to have run, so both sessions and API tokens work here
+func (a *Auth) GetUserProfile(c *gin.Context) {
+	userID := UserID(c)
+
+	// fetch user data from storage
+	user, err := a.store.Users.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+		log.Printf("Storage error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user data"})
 		return
 	}
 
-	val := session.Values["user_id"]
-	if val == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+	c.JSON(http.StatusOK, gin.H{
+		"UserID":    user.ID,
+		"Name":      user.Name,
+		"Email":     user.Email,
+		"AvatarURL": user.AvatarURL,
+		"Role":      user.Role,
+	})
+}
+
+// GET /api/account/export
+// downloads everything we store about the caller as a single JSON file
+func (a *Auth) ExportAccount(c *gin.Context) {
+	userID := UserID(c)
+
+	export, err := storage.ExportUser(c.Request.Context(), a.store, userID)
+	if err != nil {
+		log.Printf("Storage error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account data"})
 		return
 	}
 
-	userID, ok := val.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid session data"})
+	// make browsers save it as a file instead of rendering it
+	filename := fmt.Sprintf("dormant-export-%s.json", export.ExportedAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.JSON(http.StatusOK, export)
+}
+
+// DELETE /api/account
+// permanently deletes the caller's account and everything under it
+// other browsers get logged out because RequireUser checks the account still exists
+func (a *Auth) DeleteAccount(c *gin.Context) {
+	// a leaked token shouldn't be able to wipe an account
+	if c.GetBool(ContextViaToken) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "accounts can only be deleted from a browser session"})
 		return
 	}
 
-	// fetch user data from firestore
-	user, err := firestore.GetUser(c.Request.Context(), userID)
-	if err != nil {
-		log.Printf("Firestore error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user data"})
+	userID := UserID(c)
+
+	if err := a.store.Users.DeleteUser(c.Request.Context(), userID); err != nil {
+		log.Printf("Storage error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
 		return
 	}
 
-	if user == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "User profile unavailable (DB not connected)"})
-		return
+	// expire this browser's cookie right away
+	session, err := gothic.Store.Get(c.Request, "user-session")
+	if err == nil {
+		session.Values = map[interface{}]interface{}{}
+		session.Options.MaxAge = -1
+		if err := session.Save(c.Request, c.Writer); err != nil {
+			log.Println("delete account: session save error:", err)
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"UserID":    user.ID,
-		"Name":      user.Name,
-		"Email":     user.Email,
-		"AvatarURL": user.AvatarURL,
-	})
+	log.Printf("User %s deleted their account", userID)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
 
 // // // // // // // //
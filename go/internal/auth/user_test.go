@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func newAccountRouter(t *testing.T) (*gin.Engine, *memory.DB) {
+	t.Helper()
+	r, db := newTokenRouter(t)
+	a := &Auth{store: db.Store()}
+
+	account := r.Group("/api/account", a.RequireUser())
+	account.GET("/export", a.ExportAccount)
+	account.DELETE("", a.DeleteAccount)
+	return r, db
+}
+
+func TestExportAccount(t *testing.T) {
+	r, db := newAccountRouter(t)
+	ctx := t.Context()
+
+	saved, err := db.SaveSchedule(ctx, "u1", types.Schedule{Name: "Fall"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.Name = "Fall v2"
+	if _, err := db.SaveSchedule(ctx, "u1", *saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SaveSchedule(ctx, "u2", types.Schedule{Name: "not mine"}); err != nil {
+		t.Fatal(err)
+	}
+	storeToken(t, db, "notebook", "u1", true)
+
+	cookie := sessionCookie(t, map[string]interface{}{"user_id": "u1"})
+	w := send(r, http.MethodGet, "/api/account/export", "", cookie, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+		t.Errorf("export isn't a download: %q", w.Header().Get("Content-Disposition"))
+	}
+
+	stored, err := db.ListTokens(ctx, "u1")
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("tokens = %+v, %v", stored, err)
+	}
+	if strings.Contains(w.Body.String(), stored[0].Hash) {
+		t.Fatal("export leaks the token hash")
+	}
+
+	var export types.AccountExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.ID != "u1" {
+		t.Errorf("profile = %+v", export.Profile)
+	}
+	if len(export.APITokens) != 1 || export.APITokens[0].Name != "notebook" {
+		t.Errorf("tokens = %+v", export.APITokens)
+	}
+
+	schedules := export.Collections["schedules"]
+	if len(schedules) != 1 {
+		t.Fatalf("want only u1's schedule, got %+v", schedules)
+	}
+	if schedules[0].ID != saved.ID || schedules[0].Data["name"] != "Fall v2" {
+		t.Errorf("schedule = %+v", schedules[0])
+	}
+	if versions := schedules[0].Collections["versions"]; len(versions) != 2 {
+		t.Errorf("want both versions, got %+v", versions)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	r, db := newAccountRouter(t)
+	token := storeToken(t, db, "full", "u1", false)
+	cookie := sessionCookie(t, map[string]interface{}{"user_id": "u1"})
+	if _, err := db.SaveSchedule(t.Context(), "u1", types.Schedule{Name: "Fall"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// tokens can't delete accounts
+	if w := send(r, http.MethodDelete, "/api/account", token, nil, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("delete with a token: %d %s", w.Code, w.Body)
+	}
+
+	w := send(r, http.MethodDelete, "/api/account", "", cookie, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	expired := false
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookie.Name && c.MaxAge < 0 {
+			expired = true
+		}
+	}
+	if !expired {
+		t.Error("delete didn't expire the session cookie")
+	}
+
+	// the old cookie, e.g. from another browser, and the old token are both dead
+	if w := send(r, http.MethodGet, "/api/users/u1/schedules", "", cookie, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("old session after delete: %d %s", w.Code, w.Body)
+	}
+	if w := send(r, http.MethodGet, "/api/users/u1/schedules", token, nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("old token after delete: %d %s", w.Code, w.Body)
+	}
+
+	if schedules, _ := db.ListSchedules(t.Context(), "u1"); len(schedules) != 0 {
+		t.Errorf("schedules kept after delete: %+v", schedules)
+	}
+}
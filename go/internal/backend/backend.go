@@ -0,0 +1,61 @@
+package backend
+
+// picks the storage backend from env, shared by the API server and the CLI tools
+//
+// STORAGE_BACKEND=firestore (default) uses GOOGLE_PROJECT_ID
+// STORAGE_BACKEND=memory runs fully offline, seeded from STORAGE_FIXTURE if set
+// STORAGE_BACKEND=sqlite keeps everything in a single file at SQLITE_PATH (default dormant.db)
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/firestore"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/sqlite"
+)
+
+// opens the configured backend
+// the returned close func must be called on shutdown
+func Open() (storage.Store, func(), error) {
+	name := os.Getenv("STORAGE_BACKEND")
+
+	switch name {
+	case "", "firestore":
+		db, err := firestore.Open()
+		if err != nil {
+			return storage.Store{}, nil, err
+		}
+		return db.Store(), db.Close, nil
+
+	case "memory":
+		fixture := os.Getenv("STORAGE_FIXTURE")
+		if fixture == "" {
+			log.Println("memory store: no STORAGE_FIXTURE set, starting empty")
+			return memory.New().Store(), func() {}, nil
+		}
+
+		db, err := memory.Load(fixture)
+		if err != nil {
+			return storage.Store{}, nil, err
+		}
+		return db.Store(), func() {}, nil
+
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "dormant.db"
+		}
+
+		db, err := sqlite.Open(path)
+		if err != nil {
+			return storage.Store{}, nil, err
+		}
+		return db.Store(), db.Close, nil
+
+	default:
+		return storage.Store{}, nil, fmt.Errorf("unknown STORAGE_BACKEND %q", name)
+	}
+}
@@ -0,0 +1,341 @@
+package banner
+
+// client for Ellucian Banner's student registration class search (what GMU's
+// Patriot Web runs on). no API key, it behaves like a browser:
+//  1. guest handshake: load the search page, pull the synchronizer token out of the HTML
+//     (the session lives in the JSESSIONID cookie)
+//  2. pick a term, every later request is scoped to it
+//  3. search, page by page
+//
+// the session is stateful, a new search appends to the previous one unless it's
+// reset first, which SearchSections does for you.
+// sessions expire after a while of inactivity (or just randomly), when that happens
+// the client redoes the handshake and the term selection on its own, see retry.go
+// a Client is one banner session, don't share it between goroutines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"golang.org/x/time/rate"
+)
+
+const DefaultBaseURL = "https://ssbstureg.gmu.edu/StudentRegistrationSsb"
+
+// banner caps pages at 500 but large pages time out often, 50 is what the site uses
+const DefaultPageSize = 50
+
+// requests per second, about what clicking through the search page does
+// the burst lets a handshake, term selection and the first page go out back to back
+const (
+	DefaultRate  rate.Limit = 2
+	DefaultBurst            = 3
+)
+
+// returned by SectionIterator.Next when there are no more pages
+var Done = errors.New("no more pages")
+
+// a non-200 response from banner
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, 0 if there was none
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error %d: %s", e.StatusCode, e.Body)
+}
+
+type Client struct {
+	BaseURL string
+	// has a cookie jar for the session, swap the Transport to stub banner out in tests
+	HTTPClient *http.Client
+	// every request (retries included) waits for a token, just to be polite
+	// and not get rate limited by the server lol. nil means no limit
+	Limiter  *rate.Limiter
+	PageSize int
+
+	// transient failures (network errors, 429, 5xx) are retried this many times,
+	// waiting BaseBackoff, 2*BaseBackoff, ... (capped at MaxBackoff, with jitter) in between
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	token string
+	term  string
+	// bumped on every handshake, so iterators know their search was lost
+	session int
+}
+
+// an empty baseURL means GMU's production banner
+func New(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	// NOTE: the jar handles JSESSIONID automatically
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{
+			Jar:     jar,
+			Timeout: 15 * time.Second,
+		},
+		Limiter:     rate.NewLimiter(DefaultRate, DefaultBurst),
+		PageSize:    DefaultPageSize,
+		MaxRetries:  4,
+		BaseBackoff: time.Second,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+var tokenRe = regexp.MustCompile(`name="synchronizerToken"\s+content="([^"]+)"`)
+
+// starts a fresh guest session and grabs its X-Synchronizer-Token
+// the term has to be set again afterwards
+func (c *Client) Handshake(ctx context.Context) error {
+	c.token = ""
+	c.term = ""
+	c.session++
+
+	// the old JSESSIONID is what expired, start without it
+	if c.HTTPClient.Jar != nil {
+		c.HTTPClient.Jar, _ = cookiejar.New(nil)
+	}
+
+	// visit the main page just to parse the token from the HTML
+	body, err := c.do(ctx, http.MethodGet, "/ssb/classSearch/classSearch", nil)
+	if err != nil {
+		return err
+	}
+
+	matches := tokenRe.FindSubmatch(body)
+	if len(matches) < 2 {
+		// the start of the page is usually enough to tell a login wall or an outage page apart
+		return fmt.Errorf("could not find X-Synchronizer-Token in HTML: %q", snippet(body, 300))
+	}
+	c.token = string(matches[1])
+	return nil
+}
+
+// body with whitespace collapsed, cut after n bytes, for error messages
+func snippet(body []byte, n int) string {
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) > n {
+		s = s[:n] + "..."
+	}
+	return s
+}
+
+// the token of the current session, empty before Handshake
+func (c *Client) Token() string {
+	return c.token
+}
+
+// selects the term for the session, handshaking first if there's no session yet
+// term is a banner term code ex) "202610"
+func (c *Client) SetTerm(ctx context.Context, term string) error {
+	if c.token == "" {
+		if err := c.Handshake(ctx); err != nil {
+			return err
+		}
+	}
+
+	form := url.Values{}
+	form.Set("term", term)
+	form.Set("studyPath", "")
+	form.Set("studyPathText", "")
+	form.Set("startDatepicker", "")
+	form.Set("endDatepicker", "")
+
+	// NOTE: use the "uniqueSessionId" just to be safe
+	path := fmt.Sprintf("/ssb/term/search?mode=search&uniqueSessionId=guest%d", time.Now().Unix())
+	if _, err := c.do(ctx, http.MethodPost, path, form); err != nil {
+		return err
+	}
+
+	c.term = term
+	return nil
+}
+
+// the term set with SetTerm
+func (c *Client) Term() string {
+	return c.term
+}
+
+// clears the search criteria in the session
+// otherwise the next search keeps appending to the previous one
+func (c *Client) Reset(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/ssb/classSearch/resetDataForm", url.Values{})
+	return err
+}
+
+// terms banner offers in the search, newest first
+// doesn't need a session, past terms come back with "(View Only)" in the description
+func (c *Client) Terms(ctx context.Context) ([]types.BannerTerm, error) {
+	// max=30 is about ten years of fall/spring/summer
+	body, err := c.do(ctx, http.MethodGet, "/ssb/classSearch/getTerms?searchTerm=&offset=1&max=30", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []types.BannerTerm
+	if err := json.Unmarshal(body, &terms); err != nil {
+		return nil, fmt.Errorf("failed to parse terms: %w", err)
+	}
+	return terms, nil
+}
+
+// every subject offered in the current term
+func (c *Client) Subjects(ctx context.Context) ([]types.BannerSubject, error) {
+	if c.term == "" {
+		return nil, errors.New("banner: no term set")
+	}
+
+	// max=500 covers every subject GMU has
+	body, err := c.withSession(ctx, func() ([]byte, error) {
+		// the term is read inside, a renewed session keeps it
+		path := fmt.Sprintf("/ssb/classSearch/get_subject?searchTerm=&term=%s&offset=1&max=500", url.QueryEscape(c.term))
+		return c.getJSON(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []types.BannerSubject
+	if err := json.Unmarshal(body, &subjects); err != nil {
+		return nil, fmt.Errorf("failed to parse subjects: %w", err)
+	}
+	return subjects, nil
+}
+
+// one page of search results
+type Page struct {
+	Offset     int
+	TotalCount int
+	Sections   []types.BannerSection
+	// the response body as banner sent it, for keeping fixtures around
+	Raw []byte
+}
+
+// pages through the sections of one subject in the current term
+// ex)
+//
+//	it := client.SearchSections(ctx, "CS")
+//	for {
+//		page, err := it.Next()
+//		if err == banner.Done {
+//			break
+//		}
+//		...
+//	}
+type SectionIterator struct {
+	ctx     context.Context
+	client  *Client
+	subject string
+	offset  int
+	done    bool
+	// the client session our search was reset in, 0 before the first page
+	session int
+}
+
+func (c *Client) SearchSections(ctx context.Context, subject string) *SectionIterator {
+	return &SectionIterator{ctx: ctx, client: c, subject: subject}
+}
+
+// fetches the next page, Done after the last one
+// the first call resets the session's search, and so does the first call after a session renewal
+func (it *SectionIterator) Next() (*Page, error) {
+	if it.done {
+		return nil, Done
+	}
+
+	c := it.client
+	if c.term == "" {
+		return nil, errors.New("banner: no term set")
+	}
+
+	body, err := c.withSession(it.ctx, func() ([]byte, error) {
+		// a new session (first page, or renewed after expiring) has no search yet
+		if it.session != c.session {
+			if err := c.Reset(it.ctx); err != nil {
+				return nil, fmt.Errorf("failed to reset search: %w", err)
+			}
+			it.session = c.session
+		}
+
+		path := fmt.Sprintf("/ssb/searchResults/searchResults?txt_subject=%s&txt_term=%s&pageOffset=%d&pageMaxSize=%d",
+			url.QueryEscape(it.subject), url.QueryEscape(c.term), it.offset, c.PageSize)
+		return c.getJSON(it.ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response types.BannerResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse search results: %w", err)
+	}
+
+	page := &Page{Offset: it.offset, TotalCount: response.TotalCount, Sections: response.Data, Raw: body}
+	it.offset += c.PageSize
+	if len(response.Data) == 0 || it.offset >= response.TotalCount {
+		it.done = true
+	}
+	if len(response.Data) == 0 {
+		return nil, Done
+	}
+	return page, nil
+}
+
+// sends a single request with the browser-ish headers and the session token
+// form is sent url-encoded when it's not nil
+func (c *Client) send(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Synchronizer-Token", c.token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(data),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return data, nil
+}
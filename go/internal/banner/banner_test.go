@@ -0,0 +1,263 @@
+package banner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// just enough banner to search 3 sections
+type fakeBanner struct {
+	mu         sync.Mutex
+	handshakes int // also the current session number
+	resets     int
+	searches   int
+
+	// the session dies after this many searches, 0 means never
+	expireAfter int
+	// how many searches answer 503 before one goes through
+	unavailable int
+}
+
+func (f *fakeBanner) start(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /ssb/classSearch/classSearch", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.handshakes++
+		f.searches = 0
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: f.session(), Path: "/"})
+		fmt.Fprintf(w, `<meta name="synchronizerToken" content="tok-%d">`, f.handshakes)
+	})
+	mux.HandleFunc("POST /ssb/term/search", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Header.Get("X-Synchronizer-Token") == "" || r.Form.Get("term") != "202610" {
+			http.Error(w, "bad term request", http.StatusBadRequest)
+		}
+	})
+	mux.HandleFunc("POST /ssb/classSearch/resetDataForm", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.resets++
+		f.mu.Unlock()
+	})
+	mux.HandleFunc("GET /ssb/classSearch/get_subject", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"code":"CS","description":"Computer Science"},{"code":"MATH","description":"Mathematics"}]`)
+	})
+	mux.HandleFunc("GET /ssb/searchResults/searchResults", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if f.unavailable > 0 {
+			f.unavailable--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+
+		// a dead session gets the search page back, like the real thing
+		f.searches++
+		c, err := r.Cookie("JSESSIONID")
+		if err != nil || c.Value != f.session() || (f.expireAfter > 0 && f.searches > f.expireAfter) {
+			fmt.Fprint(w, "<html>class search</html>")
+			return
+		}
+
+		all := []types.BannerSection{{CRN: "1"}, {CRN: "2"}, {CRN: "3"}}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("pageOffset"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("pageMaxSize"))
+		offset = min(offset, len(all))
+		end := min(offset+size, len(all))
+		json.NewEncoder(w).Encode(types.BannerResponse{Success: true, TotalCount: len(all), Data: all[offset:end]})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeBanner) session() string {
+	return "s" + strconv.Itoa(f.handshakes)
+}
+
+// a client that doesn't wait around
+func testClient(url string) *Client {
+	c := New(url)
+	c.Limiter = nil
+	c.PageSize = 2
+	c.BaseBackoff = time.Millisecond
+	c.MaxBackoff = 5 * time.Millisecond
+	return c
+}
+
+func collectCRNs(t *testing.T, it *SectionIterator) []string {
+	var crns []string
+	for {
+		page, err := it.Next()
+		if err == Done {
+			return crns
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range page.Sections {
+			crns = append(crns, s.CRN)
+		}
+	}
+}
+
+func TestClientPagesThroughSections(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeBanner{}
+	c := testClient(f.start(t).URL)
+
+	if _, err := c.SearchSections(ctx, "CS").Next(); err == nil {
+		t.Fatal("searching without a term should fail")
+	}
+	if err := c.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() != "tok-1" || c.Term() != "202610" {
+		t.Fatalf("session not set up: token %q term %q", c.Token(), c.Term())
+	}
+
+	subjects, err := c.Subjects(ctx)
+	if err != nil || len(subjects) != 2 || subjects[1].Code != "MATH" {
+		t.Fatalf("subjects: %+v %v", subjects, err)
+	}
+
+	if crns := collectCRNs(t, c.SearchSections(ctx, "CS")); fmt.Sprint(crns) != "[1 2 3]" {
+		t.Fatalf("got CRNs %v", crns)
+	}
+	if f.resets != 1 {
+		t.Fatalf("want one reset before the search, got %d", f.resets)
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeBanner{unavailable: 2}
+	c := testClient(f.start(t).URL)
+
+	if err := c.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+	if crns := collectCRNs(t, c.SearchSections(ctx, "CS")); fmt.Sprint(crns) != "[1 2 3]" {
+		t.Fatalf("got CRNs %v", crns)
+	}
+
+	// out of retries, the last status comes back
+	f.unavailable = 10
+	c.MaxRetries = 2
+	_, err := c.SearchSections(ctx, "CS").Next()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want a 503 StatusError, got %v", err)
+	}
+	if f.unavailable != 7 {
+		t.Fatalf("want 3 attempts, got %d", 10-f.unavailable)
+	}
+}
+
+func TestClientRenewsExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeBanner{expireAfter: 1}
+	c := testClient(f.start(t).URL)
+
+	if err := c.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the session dies after the first page, the second one comes from a new session
+	if crns := collectCRNs(t, c.SearchSections(ctx, "CS")); fmt.Sprint(crns) != "[1 2 3]" {
+		t.Fatalf("got CRNs %v", crns)
+	}
+	if f.handshakes != 2 || c.Token() != "tok-2" || c.Term() != "202610" {
+		t.Fatalf("want a second handshake on the same term, got %d handshakes, token %q term %q", f.handshakes, c.Token(), c.Term())
+	}
+	// the new session's search had to be reset too
+	if f.resets != 2 {
+		t.Fatalf("want a reset per session, got %d", f.resets)
+	}
+}
+
+func TestClientGivesUpOnClientErrors(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	err := testClient(srv.URL).Handshake(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || attempts != 1 {
+		t.Fatalf("a 404 shouldn't be retried, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestHandshakeWithoutToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>\n  <title>Down for maintenance</title>\n</html>")
+	}))
+	defer srv.Close()
+	t.Chdir(t.TempDir())
+
+	err := testClient(srv.URL).Handshake(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "<html> <title>Down for maintenance</title> </html>") {
+		t.Fatalf("want the page in the error, got %v", err)
+	}
+	// nothing dumped into the working directory
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Fatalf("handshake wrote %v", entries)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// the session expires mid-search, the replay has to go through the renewal the same way
+	f := &fakeBanner{expireAfter: 1}
+	srv := f.start(t)
+	c := testClient(srv.URL)
+	c.HTTPClient.Transport = NewRecorder(dir, nil)
+
+	if err := c.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+	recorded := collectCRNs(t, c.SearchSections(ctx, "CS"))
+	srv.Close()
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = testClient("http://banner.invalid")
+	c.HTTPClient.Transport = replayer
+
+	if err := c.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+	if crns := collectCRNs(t, c.SearchSections(ctx, "CS")); fmt.Sprint(crns) != fmt.Sprint(recorded) {
+		t.Fatalf("replay got CRNs %v, recorded %v", crns, recorded)
+	}
+	if c.Token() != "tok-2" {
+		t.Fatalf("want the renewed session's token, got %q", c.Token())
+	}
+
+	// anything that wasn't recorded fails instead of going to the network
+	if _, err := c.Subjects(ctx); err == nil {
+		t.Fatal("want an error for a request that was never recorded")
+	}
+}
@@ -0,0 +1,232 @@
+package banner
+
+// record/replay for banner HTTP traffic, so the scraper can run without the network
+//
+// a Recorder sits in front of the real transport and writes every exchange to a directory,
+// one JSON file per request, numbered in the order they happened.
+// a Replayer answers from such a directory instead of the network. requests are matched by
+// method, path, query and form body (not by order), the per-request noise banner wants
+// (uniqueSessionId) is ignored. the same request asked again gets the next recording of it,
+// and the last one once they run out, so retries and renewals replay the way they were recorded
+//
+//	client := banner.New("")
+//	client.HTTPClient.Transport = banner.NewRecorder("testdata/spring", nil)
+//
+// the recordings are plain JSON and can be written by hand, see internal/scraper/testdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// returned by a Replayer for a request it has no recording of, never retried
+var ErrNotRecorded = errors.New("banner replay: nothing recorded")
+
+// response headers worth keeping, the rest is load balancer noise
+var recordedHeaders = []string{"Content-Type", "Set-Cookie", "Retry-After"}
+
+// query parameters that change on every request
+var volatileParams = []string{"uniqueSessionId", "_"}
+
+// one request and its response, as stored on disk
+type Exchange struct {
+	Method string `json:"method"`
+	// path and query, without the base URL, so recordings work against any host
+	URL  string `json:"url"`
+	Form string `json:"form,omitempty"` // url-encoded request body
+
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body"`
+}
+
+// matching key, see the top of the file
+func (e Exchange) key() string {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return e.Method + " " + e.URL
+	}
+	query := u.Query()
+	for _, p := range volatileParams {
+		query.Del(p)
+	}
+
+	// re-encoding sorts the form fields
+	form, err := url.ParseQuery(e.Form)
+	if err != nil {
+		form = url.Values{"": {e.Form}}
+	}
+	return e.Method + " " + u.Path + "?" + query.Encode() + " " + form.Encode()
+}
+
+// reads the request into an exchange, leaving the body readable for whoever sends it
+func requestExchange(req *http.Request) (Exchange, error) {
+	e := Exchange{Method: req.Method, URL: req.URL.RequestURI()}
+	if req.Body == nil {
+		return e, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return e, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	e.Form = string(body)
+	return e, nil
+}
+
+// writes every exchange to Dir as it happens
+type Recorder struct {
+	Dir string
+	// where requests actually go, nil means http.DefaultTransport
+	Transport http.RoundTripper
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewRecorder(dir string, transport http.RoundTripper) *Recorder {
+	return &Recorder{Dir: dir, Transport: transport}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	e, err := requestExchange(req)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	// network errors aren't recorded, a replay can't reproduce them anyway
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e.Status = resp.StatusCode
+	e.Body = string(body)
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Values(h); len(v) > 0 {
+			if e.Headers == nil {
+				e.Headers = make(map[string][]string)
+			}
+			e.Headers[h] = v
+		}
+	}
+
+	if err := r.save(e); err != nil {
+		return nil, fmt.Errorf("failed to record %s %s: %w", e.Method, e.URL, err)
+	}
+	return resp, nil
+}
+
+// {seq}_{method}_{path}.json ex) 0003_GET_ssb-searchResults-searchResults.json
+func (r *Recorder) save(e Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.seq++
+	path, _, _ := strings.Cut(e.URL, "?")
+	name := fmt.Sprintf("%04d_%s_%s.json", r.seq, e.Method, strings.ReplaceAll(strings.Trim(path, "/"), "/", "-"))
+	return os.WriteFile(filepath.Join(r.Dir, name), data, 0644)
+}
+
+// answers requests from recorded exchanges, never touches the network
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange // by key, in recording order
+	served    map[string]int
+}
+
+// loads every .json file in dir, in name order
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recorded exchanges in %s", dir)
+	}
+	slices.Sort(files)
+
+	r := &Replayer{exchanges: make(map[string][]Exchange), served: make(map[string]int)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var e Exchange
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		r.exchanges[e.key()] = append(r.exchanges[e.key()], e)
+	}
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	e, err := requestExchange(req)
+	if err != nil {
+		return nil, err
+	}
+	key := e.key()
+
+	r.mu.Lock()
+	recorded := r.exchanges[key]
+	n := r.served[key]
+	r.served[key]++
+	r.mu.Unlock()
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNotRecorded, e.Method, e.URL)
+	}
+	match := recorded[min(n, len(recorded)-1)]
+
+	header := make(http.Header)
+	for k, v := range match.Headers {
+		header[http.CanonicalHeaderKey(k)] = slices.Clone(v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Status, http.StatusText(match.Status)),
+		StatusCode:    match.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(match.Body)),
+		ContentLength: int64(len(match.Body)),
+		Request:       req,
+	}, nil
+}
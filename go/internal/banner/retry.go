@@ -0,0 +1,151 @@
+package banner
+
+// what makes the client survive a flaky banner:
+// - transient failures (network errors, timeouts, 429, 5xx) are retried with exponential backoff and jitter
+// - an expired session (401/403, or the HTML search page where JSON was expected) triggers
+//   a fresh guest handshake plus term selection, then the request is tried once more
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// returned when banner no longer recognizes the session and it couldn't be renewed
+var ErrSessionExpired = errors.New("banner session expired")
+
+// send with retries for transient failures
+func (c *Client) do(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		body, err := c.send(ctx, method, path, form)
+		if err == nil {
+			return body, nil
+		}
+		if attempt >= c.MaxRetries || !retryable(ctx, err) {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt, err)
+		log.Printf("banner: %s %s failed (%v), retry %d/%d in %s", method, path, err, attempt+1, c.MaxRetries, wait.Round(time.Millisecond))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// a GET whose answer has to be JSON
+// banner answers requests from a dead session with a login redirect or the search page,
+// both of which are HTML
+func (c *Client) getJSON(ctx context.Context, path string) ([]byte, error) {
+	body, err := c.do(ctx, http.MethodGet, path, nil)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden) {
+		return nil, fmt.Errorf("%w: %v", ErrSessionExpired, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '<' {
+		return nil, fmt.Errorf("%w: got HTML instead of JSON", ErrSessionExpired)
+	}
+	return body, nil
+}
+
+// runs fn, and if the session turned out to be dead, renews it and runs fn once more
+// fn must redo anything that lived in the old session, see SectionIterator.Next
+func (c *Client) withSession(ctx context.Context, fn func() ([]byte, error)) ([]byte, error) {
+	body, err := fn()
+	if !errors.Is(err, ErrSessionExpired) || c.term == "" {
+		return body, err
+	}
+
+	log.Printf("banner: %v, redoing the handshake", err)
+	if err := c.renew(ctx); err != nil {
+		return nil, fmt.Errorf("failed to renew banner session: %w", err)
+	}
+	return fn()
+}
+
+// new guest session on the same term
+func (c *Client) renew(ctx context.Context) error {
+	term := c.term
+	if err := c.Handshake(ctx); err != nil {
+		return err
+	}
+	return c.SetTerm(ctx, term)
+}
+
+// network errors and overloaded-server statuses are worth another try,
+// anything else (a 404, a bad term, a cancelled context, a missing recording) will fail the same way again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrNotRecorded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	// connection resets, timeouts, DNS hiccups, truncated bodies
+	return true
+}
+
+// exponential with jitter: somewhere between half and all of BaseBackoff * 2^attempt
+// banner's own Retry-After wins when it asks for longer
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	d := c.BaseBackoff << attempt
+	if d <= 0 || d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > d {
+		d = min(statusErr.RetryAfter, c.MaxBackoff)
+	}
+	return d
+}
+
+// only the delay-seconds form, banner doesn't send dates
+func parseRetryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
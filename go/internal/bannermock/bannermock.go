@@ -0,0 +1,227 @@
+package bannermock
+
+// a stand-in for banner's class search, to develop and test the scraper against
+// it speaks the same endpoints banner.Client uses, with the same quirks:
+//   - the class search page hands out a JSESSIONID cookie and a synchronizer token in the HTML
+//   - everything after that needs both, and the term has to be POSTed before searching
+//   - searches pile up in the session until resetDataForm is called
+//   - a dead session gets the HTML search page back instead of JSON
+//
+// sections come from a Dataset, see Load
+// run it with cmd/bannermock and point BANNER_BASE_URL at it
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// where banner lives on its host. the mock answers with and without it,
+// so BANNER_BASE_URL can differ from the real one by the host only
+const Prefix = "/StudentRegistrationSsb"
+
+// knobs for making the mock misbehave, zero values behave
+type Options struct {
+	// chance of answering any request with a 503, 0.1 is one in ten
+	FailRate float64
+	// a session stops working after this many searches, 0 means never
+	ExpireAfter int
+}
+
+type Server struct {
+	data *Dataset
+	opts Options
+	mux  *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// one guest session
+type session struct {
+	token string
+	term  string
+	// subjects searched since the last reset, banner returns all of them together
+	subjects []string
+	searches int
+}
+
+// a handler serving the dataset
+func New(data *Dataset, opts Options) *Server {
+	s := &Server{data: data, opts: opts, mux: http.NewServeMux(), sessions: make(map[string]*session)}
+
+	s.mux.HandleFunc("GET /ssb/classSearch/classSearch", s.handshake)
+	s.mux.HandleFunc("GET /ssb/classSearch/getTerms", s.terms)
+	s.mux.HandleFunc("POST /ssb/term/search", s.withSession(s.setTerm))
+	s.mux.HandleFunc("POST /ssb/classSearch/resetDataForm", s.withSession(s.reset))
+	s.mux.HandleFunc("GET /ssb/classSearch/get_subject", s.withSession(s.subjects))
+	s.mux.HandleFunc("GET /ssb/searchResults/searchResults", s.withSession(s.search))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.FailRate > 0 && rand.Float64() < s.opts.FailRate {
+		log.Printf("bannermock: failing %s %s on purpose", r.Method, r.URL.Path)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, Prefix+"/") {
+		http.StripPrefix(Prefix, s.mux).ServeHTTP(w, r)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// the class search page, which is where a guest session starts
+func (s *Server) handshake(w http.ResponseWriter, r *http.Request) {
+	id, token := randomID(), randomID()
+
+	s.mu.Lock()
+	s.sessions[id] = &session{token: token}
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: id, Path: "/", HttpOnly: true})
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, searchPage, token)
+}
+
+// doesn't need a session, like the real one
+func (s *Server) terms(w http.ResponseWriter, r *http.Request) {
+	offset, size := paging(r, "offset", "max", 10)
+	writeJSON(w, page(s.data.Terms, (offset-1)*size, size))
+}
+
+// handlers behind withSession get the caller's session, and hold s.mu while they run
+type sessionHandler func(w http.ResponseWriter, r *http.Request, sess *session)
+
+// a request without a live session, or without its token, gets the search page back
+// with a fresh token in it, which is what banner does too
+func (s *Server) withSession(next sessionHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var sess *session
+		if c, err := r.Cookie("JSESSIONID"); err == nil {
+			sess = s.sessions[c.Value]
+		}
+		if sess == nil || r.Header.Get("X-Synchronizer-Token") != sess.token {
+			w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+			fmt.Fprintf(w, searchPage, "expired")
+			return
+		}
+		next(w, r, sess)
+	}
+}
+
+func (s *Server) setTerm(w http.ResponseWriter, r *http.Request, sess *session) {
+	term := r.PostFormValue("term")
+	if !slices.ContainsFunc(s.data.Terms, func(t types.BannerTerm) bool { return t.Code == term }) {
+		http.Error(w, `{"success":false,"message":"invalid term"}`, http.StatusBadRequest)
+		return
+	}
+	sess.term = term
+	sess.subjects = nil
+	writeJSON(w, map[string]string{"fwdURL": Prefix + "/ssb/classSearch/classSearch"})
+}
+
+func (s *Server) reset(w http.ResponseWriter, r *http.Request, sess *session) {
+	sess.subjects = nil
+	writeJSON(w, true)
+}
+
+// subjects that have sections in the term, offset is a page number starting at 1
+func (s *Server) subjects(w http.ResponseWriter, r *http.Request, sess *session) {
+	offset, size := paging(r, "offset", "max", 10)
+	writeJSON(w, page(s.data.subjectsIn(r.URL.Query().Get("term")), (offset-1)*size, size))
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request, sess *session) {
+	sess.searches++
+	if s.opts.ExpireAfter > 0 && sess.searches > s.opts.ExpireAfter {
+		for id, other := range s.sessions {
+			if other == sess {
+				delete(s.sessions, id)
+			}
+		}
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		fmt.Fprintf(w, searchPage, "expired")
+		return
+	}
+
+	q := r.URL.Query()
+	if sess.term == "" || q.Get("txt_term") != sess.term {
+		writeJSON(w, types.BannerResponse{Success: false})
+		return
+	}
+	if subj := q.Get("txt_subject"); !slices.Contains(sess.subjects, subj) {
+		sess.subjects = append(sess.subjects, subj)
+	}
+
+	var matches []types.BannerSection
+	for _, sec := range s.data.Sections {
+		if sec.Term == sess.term && slices.Contains(sess.subjects, sec.Subject) {
+			matches = append(matches, sec)
+		}
+	}
+
+	offset, size := paging(r, "pageOffset", "pageMaxSize", 10)
+	writeJSON(w, types.BannerResponse{
+		Success:    true,
+		TotalCount: len(matches),
+		Data:       page(matches, offset, size),
+	})
+}
+
+// enough of the real page for the token regex in banner.Handshake
+const searchPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="synchronizerToken" content="%s">
+<title>Browse Classes</title>
+</head>
+<body><div id="classSearch">bannermock</div></body>
+</html>
+`
+
+// offset and page size from the query, with the size capped at 500 like banner
+func paging(r *http.Request, offsetParam, sizeParam string, defaultSize int) (int, int) {
+	q := r.URL.Query()
+	offset, err := strconv.Atoi(q.Get(offsetParam))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	size, err := strconv.Atoi(q.Get(sizeParam))
+	if err != nil || size <= 0 {
+		size = defaultSize
+	}
+	return offset, min(size, 500)
+}
+
+// never nil, banner sends [] for an empty page
+func page[T any](all []T, offset, size int) []T {
+	offset = min(max(offset, 0), len(all))
+	end := min(offset+size, len(all))
+	return append([]T{}, all[offset:end]...)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("bannermock: failed to write response: %v", err)
+	}
+}
+
+// session IDs and tokens only have to be unique, not secret
+func randomID() string {
+	return fmt.Sprintf("%016X%016X", rand.Uint64(), rand.Uint64())
+}
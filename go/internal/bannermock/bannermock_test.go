@@ -0,0 +1,155 @@
+package bannermock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/banner"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func newClient(t *testing.T, opts Options) *banner.Client {
+	srv := httptest.NewServer(New(Sample(), opts))
+	t.Cleanup(srv.Close)
+
+	client := banner.New(srv.URL + Prefix)
+	client.Limiter = nil
+	return client
+}
+
+// every section of a subject, across pages
+func searchAll(t *testing.T, client *banner.Client, subject string) map[string]bool {
+	t.Helper()
+	crns := make(map[string]bool)
+	it := client.SearchSections(context.Background(), subject)
+	for {
+		page, err := it.Next()
+		if err == banner.Done {
+			return crns
+		}
+		if err != nil {
+			t.Fatalf("search %s: %v", subject, err)
+		}
+		for _, s := range page.Sections {
+			if s.Subject != subject {
+				t.Errorf("search %s returned a %s section, search wasn't reset", subject, s.Subject)
+			}
+			crns[s.CRN] = true
+		}
+	}
+}
+
+func TestClientAgainstMock(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, Options{})
+
+	terms, err := client.Terms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != 3 || terms[0].Code != "202670" {
+		t.Errorf("terms = %+v", terms)
+	}
+
+	if err := client.SetTerm(ctx, "209910"); err == nil {
+		t.Error("expected an unknown term to fail")
+	}
+	if err := client.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+
+	subjects, err := client.Subjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subjects) != 3 {
+		t.Errorf("subjects = %+v", subjects)
+	}
+
+	// 60 sections, more than one page
+	if got := searchAll(t, client, "CS"); len(got) != 60 {
+		t.Errorf("CS: got %d sections, want 60", len(got))
+	}
+	// only MATH, the iterator resets the CS search first
+	if got := searchAll(t, client, "MATH"); len(got) != 23 {
+		t.Errorf("MATH: got %d sections, want 23", len(got))
+	}
+}
+
+// the quirk the client works around: a second subject without a reset returns both
+func TestSearchesPileUpWithoutReset(t *testing.T) {
+	srv := httptest.NewServer(New(Sample(), Options{}))
+	t.Cleanup(srv.Close)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(srv.URL + Prefix + "/ssb/classSearch/classSearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	token := regexp.MustCompile(`content="([^"]+)"`).FindSubmatch(html)[1]
+
+	send := func(method, path string, form url.Values) []byte {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+Prefix+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Synchronizer-Token", string(token))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return body
+	}
+	total := func(subject string) int {
+		t.Helper()
+		var r types.BannerResponse
+		body := send(http.MethodGet, "/ssb/searchResults/searchResults?txt_term=202610&pageMaxSize=500&txt_subject="+subject, nil)
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatalf("search %s: %v: %s", subject, err, body)
+		}
+		return r.TotalCount
+	}
+
+	send(http.MethodPost, "/ssb/term/search?mode=search", url.Values{"term": {"202610"}})
+	if got := total("PHYS"); got != 6 {
+		t.Errorf("PHYS = %d, want 6", got)
+	}
+	if got := total("MATH"); got != 29 {
+		t.Errorf("MATH without a reset = %d, want 29 (MATH and PHYS)", got)
+	}
+	send(http.MethodPost, "/ssb/classSearch/resetDataForm", url.Values{})
+	if got := total("MATH"); got != 23 {
+		t.Errorf("MATH after a reset = %d, want 23", got)
+	}
+}
+
+func TestExpiredSessionIsRenewed(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, Options{ExpireAfter: 1})
+	client.BaseBackoff = 0
+
+	if err := client.SetTerm(ctx, "202610"); err != nil {
+		t.Fatal(err)
+	}
+	token := client.Token()
+
+	// two pages, the session dies after the first
+	if got := searchAll(t, client, "CS"); len(got) != 60 {
+		t.Errorf("got %d sections, want 60", len(got))
+	}
+	if client.Token() == token {
+		t.Error("expected the session to be renewed")
+	}
+}
@@ -0,0 +1,115 @@
+package bannermock
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// everything the mock serves
+// terms and subjects can be left out, they're filled in from the sections
+type Dataset struct {
+	Terms    []types.BannerTerm    `json:"terms"`
+	Subjects []types.BannerSubject `json:"subjects"`
+	Sections []types.BannerSection `json:"sections"`
+}
+
+// reads a dataset from a JSON file shaped like Dataset, or from a directory of
+// BannerResponse pages like the scraper's -raw output
+func Load(path string) (*Dataset, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Dataset
+	if info.IsDir() {
+		if d.Sections, err = loadPages(path); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	d.fill()
+	return &d, nil
+}
+
+// sections of every page in dir, the same CRN in the same term only once
+func loadPages(dir string) ([]types.BannerSection, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no pages in %s", dir)
+	}
+
+	seen := make(map[string]bool)
+	var sections []types.BannerSection
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var resp types.BannerResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, s := range resp.Data {
+			if key := s.Term + "/" + s.CRN; !seen[key] {
+				seen[key] = true
+				sections = append(sections, s)
+			}
+		}
+	}
+	return sections, nil
+}
+
+// fills in missing terms and subjects and puts sections in the order banner lists them
+func (d *Dataset) fill() {
+	slices.SortStableFunc(d.Sections, func(a, b types.BannerSection) int {
+		return cmp.Or(
+			cmp.Compare(a.Term, b.Term),
+			cmp.Compare(a.Subject, b.Subject),
+			cmp.Compare(a.CourseNumber, b.CourseNumber),
+			cmp.Compare(a.SequenceNumber, b.SequenceNumber),
+		)
+	})
+
+	for _, s := range d.Sections {
+		if !slices.ContainsFunc(d.Terms, func(t types.BannerTerm) bool { return t.Code == s.Term }) {
+			d.Terms = append(d.Terms, types.BannerTerm{Code: s.Term, Description: s.Term})
+		}
+		if !slices.ContainsFunc(d.Subjects, func(sub types.BannerSubject) bool { return sub.Code == s.Subject }) {
+			d.Subjects = append(d.Subjects, types.BannerSubject{Code: s.Subject, Description: s.Subject})
+		}
+	}
+
+	// newest term first, subjects alphabetical, same as banner
+	slices.SortFunc(d.Terms, func(a, b types.BannerTerm) int { return strings.Compare(b.Code, a.Code) })
+	slices.SortFunc(d.Subjects, func(a, b types.BannerSubject) int { return strings.Compare(a.Code, b.Code) })
+}
+
+// subjects with at least one section in the term
+func (d *Dataset) subjectsIn(term string) []types.BannerSubject {
+	var subjects []types.BannerSubject
+	for _, sub := range d.Subjects {
+		if slices.ContainsFunc(d.Sections, func(s types.BannerSection) bool { return s.Term == term && s.Subject == sub.Code }) {
+			subjects = append(subjects, sub)
+		}
+	}
+	return subjects
+}
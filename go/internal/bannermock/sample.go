@@ -0,0 +1,122 @@
+package bannermock
+
+import (
+	"fmt"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// made up but banner shaped, used when no dataset is given
+// the same every time, and CS has more sections than one page of 50 holds
+func Sample() *Dataset {
+	d := &Dataset{
+		Terms: []types.BannerTerm{
+			{Code: "202670", Description: "Fall 2026"},
+			{Code: "202610", Description: "Spring 2026"},
+			{Code: "202570", Description: "Fall 2025 (View Only)"},
+		},
+		Subjects: []types.BannerSubject{
+			{Code: "CS", Description: "Computer Science"},
+			{Code: "MATH", Description: "Mathematics"},
+			{Code: "PHYS", Description: "Physics"},
+		},
+	}
+
+	courses := []struct {
+		subject, number, title string
+		sections               int
+	}{
+		{"CS", "110", "Essentials of Computer Science", 12},
+		{"CS", "211", "Object-Oriented Programming", 18},
+		{"CS", "310", "Data Structures", 20},
+		{"CS", "483", "Analysis of Algorithms", 10},
+		{"MATH", "113", "Analytic Geometry and Calculus I", 15},
+		{"MATH", "203", "Linear Algebra", 8},
+		{"PHYS", "160", "University Physics I", 6},
+	}
+	instructors := []types.BannerFaculty{
+		{DisplayName: "Lovelace, Ada", Email: "alovelace@gmu.edu"},
+		{DisplayName: "Turing, Alan", Email: "aturing@gmu.edu"},
+		{DisplayName: "Hopper, Grace", Email: "ghopper@gmu.edu"},
+		{DisplayName: "Dijkstra, Edsger", Email: "edijkstra@gmu.edu"},
+		{DisplayName: "Liskov, Barbara", Email: "bliskov@gmu.edu"},
+	}
+	// MW, TR and MWF slots through the day
+	slots := []struct {
+		days       string
+		begin, end string
+	}{
+		{"MW", "0900", "1015"}, {"MW", "1030", "1145"}, {"TR", "1200", "1315"},
+		{"TR", "1330", "1445"}, {"MWF", "0830", "0920"}, {"MW", "1630", "1745"},
+	}
+
+	// CRNs start over every term, like banner's, so the same CRN shows up in each one
+	id := 0
+	for _, term := range []string{"202670", "202610", "202570"} {
+		crn := 10000
+		for ci, c := range courses {
+			for n := 1; n <= c.sections; n++ {
+				id++
+				crn++
+				slot := slots[(ci+n)%len(slots)]
+				s := types.BannerSection{
+					ID:             id,
+					Term:           term,
+					CRN:            fmt.Sprint(crn),
+					Subject:        c.subject,
+					CourseNumber:   c.number,
+					SequenceNumber: fmt.Sprintf("%03d", n),
+					Title:          c.title,
+				}
+				primary := instructors[(ci*3+n)%len(instructors)]
+				primary.Primary = true
+				s.Faculty = []types.BannerFaculty{primary}
+				// every fourth one is co-taught
+				if n%4 == 0 {
+					s.Faculty = append(s.Faculty, instructors[(ci*3+n+1)%len(instructors)])
+				}
+
+				// every tenth section is online with no meeting time
+				if n%10 != 0 {
+					s.MeetingsFaculty = append(s.MeetingsFaculty, meeting(slot.days, slot.begin, slot.end, "Horizon Hall", fmt.Sprint(1000+n)))
+				} else {
+					s.MeetingsFaculty = append(s.MeetingsFaculty, meeting("", "", "", "", ""))
+				}
+				d.Sections = append(d.Sections, s)
+			}
+		}
+	}
+
+	d.fill()
+	return d
+}
+
+// one entry of meetingsFaculty, empty strings come out as the nulls banner sends
+func meeting(days, begin, end, building, room string) types.BannerMeetingFaculty {
+	ptr := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+
+	var m types.BannerMeetingFaculty
+	mt := &m.MeetingTime
+	mt.BeginTime, mt.EndTime = ptr(begin), ptr(end)
+	mt.Building, mt.Room = ptr(building), ptr(room)
+	for _, d := range days {
+		switch d {
+		case 'M':
+			mt.Monday = true
+		case 'T':
+			mt.Tuesday = true
+		case 'W':
+			mt.Wednesday = true
+		case 'R':
+			mt.Thursday = true
+		case 'F':
+			mt.Friday = true
+		}
+	}
+	return m
+}
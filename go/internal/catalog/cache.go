@@ -5,54 +5,55 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"time"
 
-	"cloud.google.com/go/firestore"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
-	"google.golang.org/api/iterator"
 )
 
 var (
 	cacheMu     sync.RWMutex   // lock to prevent reading while writing
 	CourseCache []types.Course // actual list of courses in RAM
+	loadedAt    time.Time      // when the cache was last swapped in
 )
 
-// fetches all courses from firestore once
+// snapshot of the cache state for the admin stats endpoint
+type Stats struct {
+	Courses  int       `json:"courses"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// fetches all courses from storage once
 // NOTE: called at server startup to warm up the cache
-func LoadCache(ctx context.Context, client *firestore.Client) error {
+func LoadCache(ctx context.Context, courses storage.CourseRepository) error {
 	log.Println("warming up course cache...")
 
 	// Optimization: We only need metadata for the search bar.
 	// We don't need 'SectionIDs' or 'Description' here if we want to save RAM,
 	// but loading everything is fine for ~5000 courses.
-	iter := client.Collection("courses").Documents(ctx)
-
-	tempCache := []types.Course{}
-
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		var c types.Course
-		if err := doc.DataTo(&c); err != nil {
-			continue
-		}
-		tempCache = append(tempCache, c)
+	tempCache, err := courses.ListCourses(ctx)
+	if err != nil {
+		return err
 	}
 
 	// lock the cache and swap the data
 	cacheMu.Lock()
 	CourseCache = tempCache
+	loadedAt = time.Now()
 	cacheMu.Unlock()
 
-	log.Printf("cache loaded: %d courses ready in RAM.", len(CourseCache))
+	log.Printf("cache loaded: %d courses ready in RAM.", len(tempCache))
 	return nil
 }
 
+// returns how many courses are cached and when they were loaded
+func CacheStats() Stats {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+
+	return Stats{Courses: len(CourseCache), LoadedAt: loadedAt}
+}
+
 // filters the in-memory list
 // this will only take microseconds to run
 // very efficient very likey
@@ -0,0 +1,87 @@
+package firestore
+
+// permanent account deletion, walks the users/{userID} document tree recursively
+// because firestore never deletes subcollections together with their parent
+// (the export is storage.ExportUser, the same for every backend)
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// permanently delete a user, every nested document under them, and their API tokens
+func (db *DB) DeleteUser(ctx context.Context, userID string) error {
+	userDoc := db.client.Collection("users").Doc(userID)
+
+	refs, err := collectDocRefs(ctx, userDoc)
+	if err != nil {
+		return err
+	}
+	refs = append(refs, userDoc)
+
+	tokenRefs, err := db.client.Collection("api_tokens").Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, snap := range tokenRefs {
+		refs = append(refs, snap.Ref)
+	}
+
+	return db.bulkDelete(ctx, refs)
+}
+
+// returns refs for every document nested under doc (not including doc itself)
+// DocumentRefs also lists "missing" docs that only exist as parents of subcollections
+func collectDocRefs(ctx context.Context, doc *firestore.DocumentRef) ([]*firestore.DocumentRef, error) {
+	var refs []*firestore.DocumentRef
+
+	colls := doc.Collections(ctx)
+	for {
+		coll, err := colls.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		children, err := coll.DocumentRefs(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			nested, err := collectDocRefs(ctx, child)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, nested...)
+			refs = append(refs, child)
+		}
+	}
+	return refs, nil
+}
+
+// deletes all refs with a BulkWriter and reports the first failure
+func (db *DB) bulkDelete(ctx context.Context, refs []*firestore.DocumentRef) error {
+	bw := db.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(refs))
+	for _, ref := range refs {
+		job, err := bw.Delete(ref)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	// End flushes everything, now check each delete actually went through
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
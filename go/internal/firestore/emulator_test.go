@@ -0,0 +1,364 @@
+package firestore
+
+// integration tests against the firestore emulator
+// start it with:
+//
+//	gcloud emulators firestore start --host-port=localhost:8080
+//
+// then run with FIRESTORE_EMULATOR_HOST=localhost:8080 go test ./internal/firestore/...
+// without the env var (or with the emulator down) every test here is skipped
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// client against the emulator, isolated per test
+// the emulator accepts any project ID, so each test gets its own empty project
+// instead of having to clean up documents between tests
+func newEmulatorDB(t *testing.T) *DB {
+	t.Helper()
+
+	host := os.Getenv("FIRESTORE_EMULATOR_HOST")
+	if host == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set, skipping emulator test")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, time.Second)
+	if err != nil {
+		t.Skipf("firestore emulator not reachable at %s: %v", host, err)
+	}
+	conn.Close()
+
+	projectID := fmt.Sprintf("dormant-test-%d", time.Now().UnixNano())
+	client, err := firestore.NewClient(context.Background(), projectID)
+	if err != nil {
+		t.Fatalf("failed to create emulator client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return &DB{client: client}
+}
+
+func TestSaveSectionLinksCourse(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	if err := db.SaveCourse(ctx, types.Course{ID: "CS110", Department: "CS", Code: "110", Title: "Essentials"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// saving the same section twice must not duplicate the link
+	for _, s := range []types.Section{
+		{ID: "10001", CourseID: "CS110", Section: "001"},
+		{ID: "10002", CourseID: "CS110", Section: "002"},
+		{ID: "10001", CourseID: "CS110", Section: "001", Professor: "Doe, Jane"},
+	} {
+		if err := db.SaveSection(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	courses, err := db.ListCourses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 1 || len(courses[0].SectionIDs) != 2 {
+		t.Fatalf("want CS110 linked to 2 sections, got %+v", courses)
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("want 2 sections, got %+v", sections)
+	}
+	for _, s := range sections {
+		if s.ID == "10001" && s.Professor != "Doe, Jane" {
+			t.Errorf("section 10001 was not overwritten: %+v", s)
+		}
+	}
+}
+
+func TestSaveSectionWithoutCourse(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	// the link fails softly, the section itself is still saved
+	if err := db.SaveSection(ctx, types.Section{ID: "20001", CourseID: "MATH113"}); err != nil {
+		t.Fatalf("SaveSection should not fail when the course is missing: %v", err)
+	}
+
+	n, err := db.CountSections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 section saved, got %d", n)
+	}
+}
+
+func TestSaveCourseSections(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	// course doesn't exist yet, it gets created together with the links
+	course := types.Course{ID: "CS310", Department: "CS", Code: "310", Title: "Data Structures"}
+	page1 := []types.Section{{ID: "40001", CourseID: "CS310"}, {ID: "40002", CourseID: "CS310"}}
+	if err := db.SaveCourseSections(ctx, course, page1); err != nil {
+		t.Fatal(err)
+	}
+
+	// next page of the same course keeps the earlier links
+	page2 := []types.Section{{ID: "40003", CourseID: "CS310"}, {ID: "40001", CourseID: "CS310"}}
+	if err := db.SaveCourseSections(ctx, course, page2); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 3 {
+		t.Fatalf("want 3 linked sections, got %+v", sections)
+	}
+
+	courses, err := db.ListCourses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 1 || courses[0].Title != "Data Structures" {
+		t.Fatalf("unexpected courses %+v", courses)
+	}
+}
+
+func TestPruneSections(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS110", Department: "CS"}, []types.Section{
+		{ID: "1", CourseID: "CS110", Term: "202610"},
+		{ID: "2", CourseID: "CS110", Term: "202610"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS499", Department: "CS"}, []types.Section{
+		{ID: "3", CourseID: "CS499", Term: "202610"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := db.PruneSections(ctx, "202610", []string{"CS"}, map[string]bool{"1": true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.DeletedSections) != 2 || len(summary.DeletedCourses) != 1 || summary.RemovedLinks != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	courses, err := db.ListCourses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 1 || len(courses[0].SectionIDs) != 1 || courses[0].SectionIDs[0] != "1" {
+		t.Fatalf("unexpected courses after prune %+v", courses)
+	}
+}
+
+func TestGetSectionsForCourse(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	// 99999 is linked but was never written, like a section deleted after the link
+	course := types.Course{ID: "CS211", Department: "CS", Code: "211", SectionIDs: []string{"30001", "99999"}}
+	if err := db.SaveCourse(ctx, course); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveSection(ctx, types.Section{ID: "30001", CourseID: "CS211", Section: "001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("skips missing sections", func(t *testing.T) {
+		sections, err := db.GetSectionsForCourse(ctx, "CS211")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sections) != 1 || sections[0].ID != "30001" {
+			t.Fatalf("want only 30001, got %+v", sections)
+		}
+	})
+
+	t.Run("no sections linked", func(t *testing.T) {
+		if err := db.SaveCourse(ctx, types.Course{ID: "CS262"}); err != nil {
+			t.Fatal(err)
+		}
+		sections, err := db.GetSectionsForCourse(ctx, "CS262")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sections) != 0 {
+			t.Fatalf("want no sections, got %+v", sections)
+		}
+	})
+
+	t.Run("missing course", func(t *testing.T) {
+		_, err := db.GetSectionsForCourse(ctx, "NOPE999")
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("want ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestSaveUserUpsert(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	if _, err := db.GetUser(ctx, "u1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("want ErrNotFound before the first save, got %v", err)
+	}
+
+	if err := db.SaveUser(ctx, types.User{ID: "u1", Name: "Old", Email: "u1@gmu.edu", Role: types.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later login has no role, it must keep the stored one
+	if err := db.SaveUser(ctx, types.User{ID: "u1", Name: "New", Email: "u1@gmu.edu"}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "New" || user.Role != types.RoleAdmin {
+		t.Fatalf("unexpected user after upsert: %+v", user)
+	}
+
+	n, err := db.CountUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 user, got %d", n)
+	}
+}
+
+func TestScheduleCRUD(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	schedules, err := db.ListSchedules(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 0 {
+		t.Fatalf("want no schedules yet, got %+v", schedules)
+	}
+
+	// create, the ID is generated
+	plan := types.Schedule{UserID: "u1", Name: "Plan A", Sections: []types.Section{{ID: "10001", CourseID: "CS110"}}}
+	saved, err := db.SaveSchedule(ctx, "u1", plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.Revision != 1 {
+		t.Fatalf("want a generated schedule ID at revision 1, got %+v", saved)
+	}
+
+	// update overwrites the whole doc
+	plan.ID = saved.ID
+	plan.Revision = saved.Revision
+	plan.Name = "Plan B"
+	plan.Sections = nil
+	if got, err := db.SaveSchedule(ctx, "u1", plan); err != nil || got.ID != saved.ID || got.Revision != 2 {
+		t.Fatalf("update returned %+v, %v", got, err)
+	}
+
+	// a second save based on the same revision is stale
+	plan.Name = "Plan C"
+	var conflict *storage.ConflictError
+	if _, err := db.SaveSchedule(ctx, "u1", plan); !errors.As(err, &conflict) || conflict.Current.Name != "Plan B" {
+		t.Fatalf("want a conflict with Plan B, got %v", err)
+	}
+
+	schedules, err = db.ListSchedules(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].Name != "Plan B" || len(schedules[0].Sections) != 0 {
+		t.Fatalf("unexpected schedules after update: %+v", schedules)
+	}
+
+	// schedules are scoped to their user
+	other, err := db.ListSchedules(ctx, "u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other) != 0 {
+		t.Fatalf("u2 should not see u1's schedules: %+v", other)
+	}
+}
+
+func TestScrapeLock(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+	now := time.Now()
+
+	if err := db.AcquireScrapeLock(ctx, types.ScrapeLock{Holder: "a", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcquireScrapeLock(ctx, types.ScrapeLock{Holder: "b", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("want ErrLocked, got %v", err)
+	}
+
+	// released, b gets it
+	if err := db.ReleaseScrapeLock(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcquireScrapeLock(ctx, types.ScrapeLock{Holder: "b", AcquiredAt: now, ExpiresAt: now.Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	// b's lease already ran out, c takes over
+	if err := db.AcquireScrapeLock(ctx, types.ScrapeLock{Holder: "c", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("expired lease wasn't taken over: %v", err)
+	}
+}
+
+func TestListSectionsByInstructor(t *testing.T) {
+	ctx := context.Background()
+	db := newEmulatorDB(t)
+
+	smith := types.Instructor{ID: "jsmith@gmu.edu", Name: "Smith, John", Email: "jsmith@gmu.edu", Primary: true}
+	sections := []types.Section{
+		{ID: "2", CourseID: "CS310", Term: "202610", Instructors: []types.Instructor{smith}},
+		{ID: "1", CourseID: "CS310", Term: "202670", Instructors: []types.Instructor{smith}},
+		{ID: "3", CourseID: "CS310", Term: "202610"},
+	}
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS310", Department: "CS", Code: "310"}, sections); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := db.ListSectionsByInstructor(ctx, "jsmith@gmu.edu", "")
+	if err != nil || len(all) != 2 || all[0].ID != "1" || all[0].Instructors[0] != smith {
+		t.Fatalf("unexpected sections %+v %v", all, err)
+	}
+	spring, err := db.ListSectionsByInstructor(ctx, "jsmith@gmu.edu", "202610")
+	if err != nil || len(spring) != 1 || spring[0].ID != "2" {
+		t.Fatalf("unexpected 202610 sections %+v %v", spring, err)
+	}
+	byName, err := db.ListSectionsByInstructor(ctx, "name:smith, john", "")
+	if err != nil || len(byName) != 2 {
+		t.Fatalf("unexpected sections by name %+v %v", byName, err)
+	}
+}
@@ -2,7 +2,10 @@ package firestore
 
 // like mentioned in auth.go, we are committing to using goth and gothic session management
 // we will be CRUD server side instead of client side fetch/writes
-// which are implemented here in this file
+// which are implemented here in this package
+//
+// DB implements every repository in the storage package,
+// nothing outside this package touches the firestore client directly
 
 import (
 	"context"
@@ -11,9 +14,13 @@ import (
 	"os"
 
 	"cloud.google.com/go/firestore"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 )
 
-var Client *firestore.Client
+type DB struct {
+	client *firestore.Client
+}
 
 // NOTE: DO NOT use json key to initialize the client unless you are ready to pay for secret manager
 // (it is okay to do in local dev, but bad practice for production and you will have to pay for it)
@@ -22,26 +29,37 @@ var Client *firestore.Client
 // hours spent realizing this mistake: ~8
 
 // init firestore client
-func Init() error {
+func Open() (*DB, error) {
 	projectID := os.Getenv("GOOGLE_PROJECT_ID")
 	if projectID == "" {
-		return errors.New("project ID is not set in env variables")
+		return nil, errors.New("project ID is not set in env variables")
 	}
 
-	var err error
 	// using background context so the client lives as long as the app lives
-	Client, err = firestore.NewClient(context.Background(), projectID)
+	client, err := firestore.NewClient(context.Background(), projectID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Println("Firestore initialized successfully")
-	return nil
+	return &DB{client: client}, nil
 }
 
-// clean up the firestore client
-func Close() {
-	if Client != nil {
-		Client.Close()
+// every repository backed by this database
+func (db *DB) Store() storage.Store {
+	return storage.Store{
+		Courses:    db,
+		Sections:   db,
+		Users:      db,
+		Schedules:  db,
+		Guests:     db,
+		Tokens:     db,
+		ScrapeRuns: db,
+		Schema:     db,
 	}
 }
+
+// clean up the firestore client
+func (db *DB) Close() {
+	db.client.Close()
+}
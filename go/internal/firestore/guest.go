@@ -0,0 +1,88 @@
+package firestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/iterator"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// guest data lives as long as the guest session cookie
+// set a firestore TTL policy on guests.expires_at to clean up abandoned guests
+const guestTTL = 30 * 24 * time.Hour
+
+// save or update a guest schedule
+// guests/{guestID}/schedules/{scheduleID}
+func (db *DB) SaveGuestSchedule(ctx context.Context, guestID string, schedule types.Schedule) (string, error) {
+	guest := db.client.Collection("guests").Doc(guestID)
+
+	// touch the parent doc so the TTL keeps getting pushed back while the guest is active
+	_, err := guest.Set(ctx, map[string]interface{}{
+		"id":         guestID,
+		"expires_at": time.Now().Add(guestTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	coll := guest.Collection("schedules")
+	if schedule.ID == "" {
+		schedule.ID = coll.NewDoc().ID
+	}
+
+	// guests have no user ID yet, it gets filled in on merge
+	schedule.UserID = ""
+	schema.StampSchedule(&schedule)
+
+	_, err = coll.Doc(schedule.ID).Set(ctx, schedule)
+	return schedule.ID, err
+}
+
+// fetch all schedules for a guest
+// guests/{guestID}/schedules/
+func (db *DB) ListGuestSchedules(ctx context.Context, guestID string) ([]types.Schedule, error) {
+	iter := db.client.Collection("guests").Doc(guestID).Collection("schedules").Documents(ctx)
+	schedules := []types.Schedule{}
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var s types.Schedule
+		if err := doc.DataTo(&s); err != nil {
+			continue
+		}
+		schema.UpgradeSchedule(&s)
+		schedules = append(schedules, s)
+	}
+	return schedules, nil
+}
+
+// guests/{guestID}/schedules/{scheduleID}
+// deleting a missing document isn't an error in firestore
+func (db *DB) DeleteGuestSchedule(ctx context.Context, guestID, scheduleID string) error {
+	_, err := db.client.Collection("guests").Doc(guestID).Collection("schedules").Doc(scheduleID).Delete(ctx)
+	return err
+}
+
+// delete a guest and all of their schedules
+// called after the schedules were merged into a real account
+func (db *DB) DeleteGuest(ctx context.Context, guestID string) error {
+	guest := db.client.Collection("guests").Doc(guestID)
+
+	// firestore doesn't delete subcollections with the parent, do it by hand
+	refs, err := guest.Collection("schedules").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	return db.bulkDelete(ctx, append(refs, guest))
+}
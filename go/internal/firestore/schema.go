@@ -0,0 +1,101 @@
+package firestore
+
+import (
+	"context"
+	"log"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// walks courses, sections and every schedules subcollection (users and guests)
+// outdated documents are upgraded and written back whole with a BulkWriter
+func (db *DB) MigrateDocuments(ctx context.Context, dryRun bool) ([]storage.MigrationResult, error) {
+	courses, err := migrateDocs(ctx, db, "courses", db.client.Collection("courses").Documents(ctx), schema.UpgradeCourse, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// upgraded sections get their instructor_ids too, older ones have none
+	upgradeSection := func(s *types.Section) bool {
+		if !schema.UpgradeSection(s) {
+			return false
+		}
+		s.InstructorIDs = types.InstructorKeys(s.Instructors)
+		return true
+	}
+	sections, err := migrateDocs(ctx, db, "sections", db.client.Collection("sections").Documents(ctx), upgradeSection, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// collection group query reaches users/*/schedules and guests/*/schedules alike
+	schedules, err := migrateDocs(ctx, db, "schedules", db.client.CollectionGroup("schedules").Documents(ctx), schema.UpgradeSchedule, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return []storage.MigrationResult{courses, sections, schedules}, nil
+}
+
+func migrateDocs[T any](ctx context.Context, db *DB, name string, iter *firestore.DocumentIterator, upgrade func(*T) bool, dryRun bool) (storage.MigrationResult, error) {
+	result := storage.MigrationResult{Collection: name, Failed: []string{}}
+
+	bw := db.client.BulkWriter(ctx)
+
+	type pending struct {
+		id  string
+		job *firestore.BulkWriterJob
+	}
+	var jobs []pending
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			bw.End()
+			return result, err
+		}
+		result.Scanned++
+
+		var v T
+		if err := doc.DataTo(&v); err != nil {
+			log.Printf("migrate: skipping unreadable %s: %v", doc.Ref.Path, err)
+			result.Failed = append(result.Failed, doc.Ref.ID)
+			continue
+		}
+		if !upgrade(&v) {
+			continue
+		}
+		result.Outdated++
+
+		if dryRun {
+			continue
+		}
+
+		// these documents have no fields outside their struct, so a full Set is safe
+		job, err := bw.Set(doc.Ref, v)
+		if err != nil {
+			result.Failed = append(result.Failed, doc.Ref.ID)
+			continue
+		}
+		jobs = append(jobs, pending{id: doc.Ref.ID, job: job})
+	}
+	bw.End()
+
+	for _, p := range jobs {
+		if _, err := p.job.Results(); err != nil {
+			log.Printf("migrate: failed to write %s/%s: %v", name, p.id, err)
+			result.Failed = append(result.Failed, p.id)
+			continue
+		}
+		result.Upgraded++
+	}
+	return result, nil
+}
@@ -2,23 +2,30 @@ package firestore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // saves the high-level course metadata ex) CS101, Title
 // we use map to avoid redundant writes in the scraper
-func SaveCourse(ctx context.Context, course types.Course) error {
-	if Client == nil {
-		return nil
-	}
-
+func (db *DB) SaveCourse(ctx context.Context, course types.Course) error {
 	// this will overwrite existing course data if the course ID already exists
 	// which is fine since we want the latest data
-	_, err := Client.Collection("courses").Doc(course.ID).Set(ctx, course)
+	schema.StampCourse(&course)
+	_, err := db.client.Collection("courses").Doc(course.ID).Set(ctx, course)
 	if err != nil {
 		log.Printf("Failed to save course %s: %v", course.ID, err)
 		return err
@@ -26,13 +33,39 @@ func SaveCourse(ctx context.Context, course types.Course) error {
 	return nil
 }
 
-// saves a specific class section ex) CS101-001, Time, Location
-func SaveSection(ctx context.Context, section types.Section) error {
-	if Client == nil {
-		return nil
+// fetches every course document
+// used to warm up the catalog cache, ~5000 docs so one pass is fine
+func (db *DB) ListCourses(ctx context.Context) ([]types.Course, error) {
+	iter := db.client.Collection("courses").Documents(ctx)
+
+	courses := []types.Course{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var c types.Course
+		if err := doc.DataTo(&c); err != nil {
+			continue
+		}
+		schema.UpgradeCourse(&c)
+		courses = append(courses, c)
 	}
+	return courses, nil
+}
 
-	_, err := Client.Collection("sections").Doc(section.ID).Set(ctx, section)
+func (db *DB) CountCourses(ctx context.Context) (int64, error) {
+	return db.countDocuments(ctx, "courses")
+}
+
+// saves a specific class section ex) CS101-001, Time, Location
+func (db *DB) SaveSection(ctx context.Context, section types.Section) error {
+	stampSection(&section)
+	_, err := db.client.Collection("sections").Doc(section.ID).Set(ctx, section)
 	if err != nil {
 		return err
 	}
@@ -42,15 +75,15 @@ func SaveSection(ctx context.Context, section types.Section) error {
 	// then do a direct batch fetch for those section IDs
 
 	// firestore "ArrayUnion" adds the ID only if it's not already there
-	_, err = Client.Collection("courses").Doc(section.CourseID).Update(ctx, []firestore.Update{
+	_, err = db.client.Collection("courses").Doc(section.CourseID).Update(ctx, []firestore.Update{
 		{
 			Path:  "section_ids",
 			Value: firestore.ArrayUnion(section.ID),
 		},
 	})
 
-	// Note: If the course doc doesn't exist yet, Update() might fail.
-	// Since you save the Course object first in your main loop, this is safe.
+	// Note: If the course doc doesn't exist yet, Update() fails.
+	// the scraper uses SaveCourseSections instead, which can't hit this
 	if err != nil {
 		// Log warning but don't fail the whole scrape
 		log.Printf("Warning: Failed to link section %s to course %s: %v", section.ID, section.CourseID, err)
@@ -59,12 +92,213 @@ func SaveSection(ctx context.Context, section types.Section) error {
 	return nil
 }
 
+// a single commit can hold at most 500 writes, one of them is the course
+const maxBatchWrites = 500
+
+// saves a course and a group of its sections in one transaction per chunk
+// the course is created if it's missing and every section is linked with ArrayUnion,
+// so a section can never end up saved but unlinked like with SaveSection
+func (db *DB) SaveCourseSections(ctx context.Context, course types.Course, sections []types.Section) error {
+	courseRef := db.client.Collection("courses").Doc(course.ID)
+
+	var failures []storage.WriteFailure
+	for start := 0; ; start += maxBatchWrites - 1 {
+		end := min(start+maxBatchWrites-1, len(sections))
+		chunk := sections[start:end]
+
+		ids := make([]interface{}, len(chunk))
+		for i, s := range chunk {
+			ids[i] = s.ID
+		}
+
+		// no reads, so this is just an atomic commit of every write below
+		err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			// merge so the existing section_ids survive, unlike SaveCourse
+			fields := map[string]interface{}{
+				"id":          course.ID,
+				"department":  course.Department,
+				"code":        course.Code,
+				"title":       course.Title,
+				"description": course.Description,
+				"credits":     course.Credits,
+			}
+			if len(ids) > 0 {
+				fields["section_ids"] = firestore.ArrayUnion(ids...)
+				// section_ids is an array after this, which is all version 1 asks for
+				fields["schema_version"] = schema.CourseVersion()
+			}
+			if err := tx.Set(courseRef, fields, firestore.MergeAll); err != nil {
+				return err
+			}
+
+			for _, s := range chunk {
+				stampSection(&s)
+				if err := tx.Set(db.client.Collection("sections").Doc(s.ID), s); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		// all or nothing, so every document in the chunk failed
+		if err != nil {
+			log.Printf("Failed to save course %s with %d sections: %v", course.ID, len(chunk), err)
+			failures = append(failures, storage.WriteFailure{Collection: "courses", ID: course.ID, Err: err})
+			for _, s := range chunk {
+				failures = append(failures, storage.WriteFailure{Collection: "sections", ID: s.ID, Err: err})
+			}
+		}
+
+		if end >= len(sections) {
+			break
+		}
+	}
+
+	if len(failures) > 0 {
+		return &storage.BatchError{Failures: failures}
+	}
+	return nil
+}
+
+// firestore "in" filters take at most 30 values
+const maxInValues = 30
+
+// reads every course to find the ones in scope (~5000 docs, same as the catalog cache),
+// then only the sections of those courses
+func (db *DB) PruneSections(ctx context.Context, term string, subjects []string, seen map[string]bool, legacy bool) (storage.PruneSummary, error) {
+	var summary storage.PruneSummary
+
+	courses, err := db.ListCourses(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	var scoped []types.Course
+	for _, c := range courses {
+		if slices.Contains(subjects, c.Department) {
+			scoped = append(scoped, c)
+		}
+	}
+
+	// stale sections of the term, and which sections survive
+	var staleRefs []*firestore.DocumentRef
+	remaining := make(map[string]bool)
+	remainingPerCourse := make(map[string]int)
+
+	for start := 0; start < len(scoped); start += maxInValues {
+		end := min(start+maxInValues, len(scoped))
+		ids := make([]interface{}, 0, end-start)
+		for _, c := range scoped[start:end] {
+			ids = append(ids, c.ID)
+		}
+
+		iter := db.client.Collection("sections").Where("course_id", "in", ids).Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return summary, err
+			}
+
+			var s types.Section
+			if err := doc.DataTo(&s); err != nil {
+				continue
+			}
+
+			if (s.Term == term || legacy && s.Term == "") && !seen[s.ID] {
+				staleRefs = append(staleRefs, doc.Ref)
+				summary.DeletedSections = append(summary.DeletedSections, s.ID)
+				continue
+			}
+			remaining[s.ID] = true
+			remainingPerCourse[s.CourseID]++
+		}
+	}
+
+	if err := db.bulkDelete(ctx, staleRefs); err != nil {
+		return summary, fmt.Errorf("failed to delete stale sections: %w", err)
+	}
+
+	// links we can't account for might still point at a section filed under
+	// another course, look those up in one round trip before calling them dangling
+	var unknown []*firestore.DocumentRef
+	for _, c := range scoped {
+		for _, id := range c.SectionIDs {
+			if !remaining[id] && !seen[id] {
+				unknown = append(unknown, db.client.Collection("sections").Doc(id))
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		snaps, err := db.client.GetAll(ctx, unknown)
+		if err != nil {
+			return summary, err
+		}
+		for _, snap := range snaps {
+			if snap.Exists() {
+				remaining[snap.Ref.ID] = true
+			}
+		}
+	}
+
+	// fix up the parent courses
+	bw := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, c := range scoped {
+		ref := db.client.Collection("courses").Doc(c.ID)
+
+		// keep links to sections that exist, or that banner still lists
+		var dangling []interface{}
+		kept := 0
+		for _, id := range c.SectionIDs {
+			if remaining[id] || seen[id] {
+				kept++
+				continue
+			}
+			dangling = append(dangling, id)
+		}
+
+		var job *firestore.BulkWriterJob
+		var err error
+		switch {
+		case remainingPerCourse[c.ID] == 0 && kept == 0:
+			job, err = bw.Delete(ref)
+			summary.DeletedCourses = append(summary.DeletedCourses, c.ID)
+		case len(dangling) > 0:
+			job, err = bw.Update(ref, []firestore.Update{
+				{Path: "section_ids", Value: firestore.ArrayRemove(dangling...)},
+			})
+			summary.RemovedLinks += len(dangling)
+		default:
+			continue
+		}
+		if err != nil {
+			bw.End()
+			return summary, err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return summary, fmt.Errorf("failed to update courses: %w", err)
+		}
+	}
+	return summary, nil
+}
+
 // fetch all sections for a specific course ID
 // "CS110" for example
 // uses the "section_ids" index.
-func GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section, error) {
+func (db *DB) GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section, error) {
 	// fetch the course document first
-	dsnap, err := Client.Collection("courses").Doc(courseID).Get(ctx)
+	dsnap, err := db.client.Collection("courses").Doc(courseID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("failed to find course %s: %w", courseID, storage.ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find course %s: %v", courseID, err)
 	}
@@ -73,6 +307,7 @@ func GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section
 	if err := dsnap.DataTo(&course); err != nil {
 		return nil, fmt.Errorf("failed to parse course data: %v", err)
 	}
+	schema.UpgradeCourse(&course)
 
 	// check if there are any sections linked
 	if len(course.SectionIDs) == 0 {
@@ -85,12 +320,12 @@ func GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section
 	// efficient for courses with many sections like mentioned at the top
 	docRefs := make([]*firestore.DocumentRef, len(course.SectionIDs))
 	for i, secID := range course.SectionIDs {
-		docRefs[i] = Client.Collection("sections").Doc(secID)
+		docRefs[i] = db.client.Collection("sections").Doc(secID)
 	}
 
 	// getall retrieves multiple documents in a single network round-trip
 	// more efficient than querying one by one
-	sectionSnaps, err := Client.GetAll(ctx, docRefs)
+	sectionSnaps, err := db.client.GetAll(ctx, docRefs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch sections: %v", err)
 	}
@@ -105,9 +340,249 @@ func GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section
 		}
 		var s types.Section
 		if err := snap.DataTo(&s); err == nil {
+			schema.UpgradeSection(&s)
 			sections = append(sections, s)
 		}
 	}
 
 	return sections, nil
 }
+
+// whole sections collection, or one term of it
+// used by the backup tool, the app itself always goes through a course
+func (db *DB) ListSections(ctx context.Context, term string) ([]types.Section, error) {
+	query := db.client.Collection("sections").Query
+	if term != "" {
+		query = query.Where("term", "==", term)
+	}
+	iter := query.Documents(ctx)
+
+	sections := []types.Section{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var s types.Section
+		if err := doc.DataTo(&s); err != nil {
+			log.Printf("Skipping unreadable section %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		schema.UpgradeSection(&s)
+		sections = append(sections, s)
+	}
+	return sections, nil
+}
+
+// array-contains on instructor_ids is served by firestore's automatic single field index
+// the term is filtered here, together they'd need a composite index
+func (db *DB) ListSectionsByInstructor(ctx context.Context, instructorID, term string) ([]types.Section, error) {
+	iter := db.client.Collection("sections").Where("instructor_ids", "array-contains", instructorID).Documents(ctx)
+
+	sections := []types.Section{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var s types.Section
+		if err := doc.DataTo(&s); err != nil {
+			log.Printf("Skipping unreadable section %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if term != "" && s.Term != term {
+			continue
+		}
+		schema.UpgradeSection(&s)
+		sections = append(sections, s)
+	}
+	slices.SortFunc(sections, func(a, b types.Section) int { return strings.Compare(a.ID, b.ID) })
+	return sections, nil
+}
+
+// schema.StampSection plus the instructor_ids the instructor queries need
+func stampSection(s *types.Section) {
+	schema.StampSection(s)
+	s.InstructorIDs = types.InstructorKeys(s.Instructors)
+}
+
+func (db *DB) CountSections(ctx context.Context) (int64, error) {
+	return db.countDocuments(ctx, "sections")
+}
+
+// save or update a scrape run record
+// scrape_runs/{runID}
+func (db *DB) SaveScrapeRun(ctx context.Context, run types.ScrapeRun) error {
+	_, err := db.client.Collection("scrape_runs").Doc(run.ID).Set(ctx, run)
+	return err
+}
+
+// fetch the most recent scrape runs, newest first
+func (db *DB) ListScrapeRuns(ctx context.Context, limit int) ([]types.ScrapeRun, error) {
+	iter := db.client.Collection("scrape_runs").
+		OrderBy("started_at", firestore.Desc).
+		Limit(limit).
+		Documents(ctx)
+
+	runs := []types.ScrapeRun{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var r types.ScrapeRun
+		if err := doc.DataTo(&r); err != nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+// fetch a single scrape run by ID
+func (db *DB) GetScrapeRun(ctx context.Context, runID string) (*types.ScrapeRun, error) {
+	doc, err := db.client.Collection("scrape_runs").Doc(runID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var run types.ScrapeRun
+	if err := doc.DataTo(&run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// one document per entry, scrape_runs/{runID}/changes/{sectionID}_{kind}
+// a first run adds every section, so this goes through a BulkWriter instead of batches
+func (db *DB) SaveScrapeChanges(ctx context.Context, runID string, changes []types.SectionChange) error {
+	changesRef := db.client.Collection("scrape_runs").Doc(runID).Collection("changes")
+
+	bw := db.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(changes))
+	for _, c := range changes {
+		job, err := bw.Set(changesRef.Doc(c.SectionID+"_"+c.Kind), c)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("failed to save change log of run %s: %w", runID, err)
+		}
+	}
+	return nil
+}
+
+// sorted here, ordering by three fields would need a composite index
+func (db *DB) ListScrapeChanges(ctx context.Context, runID string) ([]types.SectionChange, error) {
+	iter := db.client.Collection("scrape_runs").Doc(runID).Collection("changes").Documents(ctx)
+
+	changes := []types.SectionChange{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var c types.SectionChange
+		if err := doc.DataTo(&c); err != nil {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	storage.SortChanges(changes)
+	return changes, nil
+}
+
+// scrape_locks/scraper
+// read and written in one transaction, so two processes can't both see it free
+func (db *DB) AcquireScrapeLock(ctx context.Context, lock types.ScrapeLock) error {
+	ref := db.client.Collection("scrape_locks").Doc("scraper")
+
+	return db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if err == nil {
+			var current types.ScrapeLock
+			if err := snap.DataTo(&current); err != nil {
+				return err
+			}
+			// NOTE: expiry is judged by our clock, leases are minutes long so a little skew is fine
+			if current.Holder != lock.Holder && time.Now().Before(current.ExpiresAt) {
+				return &storage.LockedError{Current: current}
+			}
+		}
+		return tx.Set(ref, lock)
+	})
+}
+
+func (db *DB) ReleaseScrapeLock(ctx context.Context, holder string) error {
+	ref := db.client.Collection("scrape_locks").Doc("scraper")
+
+	return db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var current types.ScrapeLock
+		if err := snap.DataTo(&current); err != nil {
+			return err
+		}
+		// someone took over after our lease ran out, it's theirs now
+		if current.Holder != holder {
+			return nil
+		}
+		return tx.Delete(ref)
+	})
+}
+
+// count documents in a root collection without reading them all
+// uses an aggregation query so it costs one read per 1000 docs
+func (db *DB) countDocuments(ctx context.Context, collection string) (int64, error) {
+	res, err := db.client.Collection(collection).NewAggregationQuery().WithCount("all").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, ok := res["all"]
+	if !ok {
+		return 0, errors.New("count missing from aggregation result")
+	}
+
+	// the value comes back as a protobuf Value
+	v, ok := count.(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count type %T", count)
+	}
+	return v.GetIntegerValue(), nil
+}
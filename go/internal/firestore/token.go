@@ -0,0 +1,87 @@
+package firestore
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// save a new API token
+// api_tokens/{tokenID}
+// kept in a root collection so the auth middleware can look it up by ID alone
+func (db *DB) SaveToken(ctx context.Context, token types.APIToken) error {
+	_, err := db.client.Collection("api_tokens").Doc(token.ID).Set(ctx, token)
+	return err
+}
+
+// fetch a token by ID
+// api_tokens/{tokenID}
+func (db *DB) GetToken(ctx context.Context, tokenID string) (*types.APIToken, error) {
+	doc, err := db.client.Collection("api_tokens").Doc(tokenID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token types.APIToken
+	if err := doc.DataTo(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// fetch all tokens owned by a user
+func (db *DB) ListTokens(ctx context.Context, userID string) ([]types.APIToken, error) {
+	iter := db.client.Collection("api_tokens").Where("user_id", "==", userID).Documents(ctx)
+	tokens := []types.APIToken{}
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var t types.APIToken
+		if err := doc.DataTo(&t); err != nil {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// delete a token, only if it belongs to the given user
+func (db *DB) DeleteToken(ctx context.Context, userID, tokenID string) error {
+	token, err := db.GetToken(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+
+	// don't leak whether someone else's token exists
+	if token.UserID != userID {
+		return storage.ErrNotFound
+	}
+
+	_, err = db.client.Collection("api_tokens").Doc(tokenID).Delete(ctx)
+	return err
+}
+
+// record when a token was last used
+func (db *DB) TouchToken(ctx context.Context, tokenID string) error {
+	_, err := db.client.Collection("api_tokens").Doc(tokenID).Update(ctx, []firestore.Update{
+		{Path: "last_used_at", Value: time.Now()},
+	})
+	return err
+}
@@ -3,29 +3,38 @@ package firestore
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 )
 
 // save or update user document after authentication
 // users/{userID}
-func SaveUserToFirestore(ctx context.Context, user types.User) error {
-	if Client == nil {
-		return errors.New("firestore client is not initialized")
-	}
-
+func (db *DB) SaveUser(ctx context.Context, user types.User) error {
 	// update existing fields or create if new
-	_, err := Client.Collection("users").Doc(user.ID).Set(ctx, map[string]interface{}{
+	fields := map[string]interface{}{
 		"id":         user.ID,
 		"name":       user.Name,
 		"email":      user.Email,
 		"avatar_url": user.AvatarURL,
-	}, firestore.MergeAll)
+	}
+
+	// only write the role when one is given
+	// so a login never wipes a role that was granted by hand in the console
+	if user.Role != "" {
+		fields["role"] = user.Role
+	}
+
+	_, err := db.client.Collection("users").Doc(user.ID).Set(ctx, fields, firestore.MergeAll)
 
 	if err != nil {
 		log.Printf("Firestore write error: %v", err)
@@ -38,12 +47,11 @@ func SaveUserToFirestore(ctx context.Context, user types.User) error {
 
 // fetch user document by ID
 // users/{userID}
-func GetUser(ctx context.Context, userID string) (*types.User, error) {
-	if Client == nil {
-		return nil, errors.New("firestore client is not initialized")
+func (db *DB) GetUser(ctx context.Context, userID string) (*types.User, error) {
+	doc, err := db.client.Collection("users").Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, storage.ErrNotFound
 	}
-
-	doc, err := Client.Collection("users").Doc(userID).Get(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -56,16 +64,41 @@ func GetUser(ctx context.Context, userID string) (*types.User, error) {
 	return &user, nil
 }
 
-// save or update user schedule in subcollection
-// users/{userID}/schedules/{scheduleID}
-func SaveUserSchedule(ctx context.Context, userID string, schedule types.Schedule) error {
-	if Client == nil {
-		return fmt.Errorf("firestore client is not initialized")
+// users/
+func (db *DB) ListUsers(ctx context.Context) ([]types.User, error) {
+	iter := db.client.Collection("users").Documents(ctx)
+
+	users := []types.User{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var user types.User
+		if err := doc.DataTo(&user); err != nil {
+			log.Printf("Skipping unreadable user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		users = append(users, user)
 	}
+	return users, nil
+}
 
+func (db *DB) CountUsers(ctx context.Context) (int64, error) {
+	return db.countDocuments(ctx, "users")
+}
+
+// save or update user schedule in subcollection
+// users/{userID}/schedules/{scheduleID}
+// every save is also appended to users/{userID}/schedules/{scheduleID}/versions/{version}
+func (db *DB) SaveSchedule(ctx context.Context, userID string, schedule types.Schedule) (*types.Schedule, error) {
 	// reference subcollection
 	// users/{userID}/schedules/{scheduleID}
-	coll := Client.Collection("users").Doc(userID).Collection("schedules")
+	coll := db.client.Collection("users").Doc(userID).Collection("schedules")
 
 	// create a new Doc ID if one doesn't exist
 	if schedule.ID == "" {
@@ -74,19 +107,171 @@ func SaveUserSchedule(ctx context.Context, userID string, schedule types.Schedul
 
 	// save the full struct
 	// the approach here is snapshot based; we overwrite the whole doc each time
-	_, err := coll.Doc(schedule.ID).Set(ctx, schedule)
-	return err
+	schema.StampSchedule(&schedule)
+
+	ref := coll.Doc(schedule.ID)
+	versions := ref.Collection("versions")
+	based := schedule.Revision
+
+	// transaction so two saves can't both claim the same version number
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// a transaction retry must start from what the caller sent, not the last attempt
+		schedule.Revision = based
+
+		snap, err := tx.Get(ref)
+		exists := err == nil
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		// a missing schedule counts as revision 0
+		if exists {
+			var current types.Schedule
+			if err := snap.DataTo(&current); err != nil {
+				return err
+			}
+			if current.Revision != schedule.Revision {
+				schema.UpgradeSchedule(&current)
+				return &storage.ConflictError{Current: &current}
+			}
+		} else if schedule.Revision != 0 {
+			return &storage.ConflictError{}
+		}
+
+		// history is bounded, so reading all of it is at most ScheduleHistoryLimit docs
+		snaps, err := tx.Documents(versions.OrderBy("version", firestore.Desc)).GetAll()
+		if err != nil {
+			return err
+		}
+
+		next := 1
+		if len(snaps) > 0 {
+			var latest types.ScheduleVersion
+			if err := snaps[0].DataTo(&latest); err != nil {
+				return err
+			}
+			next = latest.Version + 1
+		}
+
+		// make room for the new version
+		for _, snap := range snaps[min(len(snaps), storage.ScheduleHistoryLimit-1):] {
+			if err := tx.Delete(snap.Ref); err != nil {
+				return err
+			}
+		}
+
+		schedule.UserID = userID
+		schedule.Revision = next
+		schedule.UpdatedAt = time.Now()
+
+		// the update time precondition makes the write itself fail if the document
+		// changed after we read it, and Create fails if someone else created it first
+		if exists {
+			err = tx.Update(ref, []firestore.Update{
+				{Path: "id", Value: schedule.ID},
+				{Path: "user_id", Value: schedule.UserID},
+				{Path: "name", Value: schedule.Name},
+				{Path: "sections", Value: schedule.Sections},
+				{Path: "revision", Value: schedule.Revision},
+				{Path: "updated_at", Value: schedule.UpdatedAt},
+				{Path: "schema_version", Value: schedule.SchemaVersion},
+			}, firestore.LastUpdateTime(snap.UpdateTime))
+		} else {
+			err = tx.Create(ref, schedule)
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Set(versions.Doc(strconv.Itoa(next)), types.ScheduleVersion{
+			Version:  next,
+			SavedAt:  schedule.UpdatedAt,
+			Schedule: schedule,
+		})
+	})
+
+	// lost the race at commit time, report whatever won
+	if code := status.Code(err); code == codes.FailedPrecondition || code == codes.AlreadyExists {
+		current, getErr := db.GetSchedule(ctx, userID, schedule.ID)
+		if getErr != nil && !errors.Is(getErr, storage.ErrNotFound) {
+			return nil, getErr
+		}
+		return nil, &storage.ConflictError{Current: current}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
 }
 
-// fetch all schedules for a user
-// users/{userID}/schedules/
-func GetUserSchedules(ctx context.Context, userID string) ([]types.Schedule, error) {
-	if Client == nil {
-		return nil, fmt.Errorf("database client is not initialized")
+// users/{userID}/schedules/{scheduleID}
+func (db *DB) GetSchedule(ctx context.Context, userID, scheduleID string) (*types.Schedule, error) {
+	doc, err := db.client.Collection("users").Doc(userID).Collection("schedules").Doc(scheduleID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s types.Schedule
+	if err := doc.DataTo(&s); err != nil {
+		return nil, err
 	}
+	schema.UpgradeSchedule(&s)
+	return &s, nil
+}
 
+// history of a schedule, newest first
+// users/{userID}/schedules/{scheduleID}/versions/
+func (db *DB) ListScheduleVersions(ctx context.Context, userID, scheduleID string) ([]types.ScheduleVersion, error) {
+	iter := db.client.Collection("users").Doc(userID).Collection("schedules").Doc(scheduleID).
+		Collection("versions").OrderBy("version", firestore.Desc).Documents(ctx)
+
+	versions := []types.ScheduleVersion{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var v types.ScheduleVersion
+		if err := doc.DataTo(&v); err != nil {
+			continue
+		}
+		schema.UpgradeSchedule(&v.Schedule)
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// users/{userID}/schedules/{scheduleID}/versions/{version}
+func (db *DB) GetScheduleVersion(ctx context.Context, userID, scheduleID string, version int) (*types.ScheduleVersion, error) {
+	doc, err := db.client.Collection("users").Doc(userID).Collection("schedules").Doc(scheduleID).
+		Collection("versions").Doc(strconv.Itoa(version)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var v types.ScheduleVersion
+	if err := doc.DataTo(&v); err != nil {
+		return nil, err
+	}
+	schema.UpgradeSchedule(&v.Schedule)
+	return &v, nil
+}
+
+// fetch all schedules for a user
+// users/{userID}/schedules/
+func (db *DB) ListSchedules(ctx context.Context, userID string) ([]types.Schedule, error) {
 	// fetch all docs in the subcollection
-	iter := Client.Collection("users").Doc(userID).Collection("schedules").Documents(ctx)
+	iter := db.client.Collection("users").Doc(userID).Collection("schedules").Documents(ctx)
 	var schedules []types.Schedule
 
 	for {
@@ -104,6 +289,7 @@ func GetUserSchedules(ctx context.Context, userID string) ([]types.Schedule, err
 		if err := doc.DataTo(&s); err != nil {
 			continue
 		}
+		schema.UpgradeSchedule(&s)
 		schedules = append(schedules, s)
 	}
 	return schedules, nil
@@ -0,0 +1,33 @@
+package jsonl
+
+// one JSON document per line, the file format of cmd/backup
+// and of the scraper's jsonl export, which cmd/backup imports
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// creates or truncates path and writes every doc on its own line
+func Write[T any](path string, docs []T) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
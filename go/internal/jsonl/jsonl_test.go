@@ -0,0 +1,30 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "courses.jsonl")
+	type doc struct {
+		ID string `json:"id"`
+	}
+
+	// an existing file is replaced, not appended to
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, []doc{{"CS110"}, {"CS211"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"id\":\"CS110\"}\n{\"id\":\"CS211\"}\n"; string(data) != want {
+		t.Fatalf("got %q, want %q", data, want)
+	}
+}
@@ -21,12 +21,13 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
 	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
 )
 
-func Run(ctx context.Context, courseIDs []string, userID string) ([]types.Schedule, error) {
+func Run(ctx context.Context, repo storage.SectionRepository, courseIDs []string, userID string) ([]types.Schedule, error) {
 	// 1. fetch the specific sections for the courses the user selected
-	sections, err := GetSectionsByCourseIDs(ctx, courseIDs)
+	sections, err := GetSectionsByCourseIDs(ctx, repo, courseIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch sections: %w", err)
 	}
@@ -49,11 +50,19 @@ func Run(ctx context.Context, courseIDs []string, userID string) ([]types.Schedu
 
 // fetches detailed meeting times (Mon/Wed 10am)
 // crucial for generating permutations and checking conflicts
-func GetSectionsByCourseIDs(ctx context.Context, courseIDs []string) ([]types.Section, error) {
-	// TODO: connect this to your actual firestore
-	// query: SELECT * FROM sections WHERE course_id IN (courseIDs...)
+func GetSectionsByCourseIDs(ctx context.Context, repo storage.SectionRepository, courseIDs []string) ([]types.Section, error) {
+	// one lookup per course through the section_ids index
+	// max 7 courses per request so this stays cheap
+	sections := []types.Section{}
+	for _, id := range courseIDs {
+		found, err := repo.GetSectionsForCourse(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, found...)
+	}
 
-	return []types.Section{}, nil
+	return sections, nil
 }
 
 // generatePermutations is a placeholder for your conflict-detection algorithm
@@ -0,0 +1,129 @@
+package schema
+
+// versioning for the documents we store as-is (courses, sections, schedules)
+//
+// every stored document carries schema_version, 0 means it was written before versioning existed.
+// to change a stored shape:
+//  1. append an upgrade step below, the new version is the number of steps
+//  2. that's it, readers and writers pick it up
+//
+// backends run Upgrade* on everything they read, so handlers never see an old shape,
+// and Stamp* on everything they write, so new documents carry the current version.
+// cmd/migrate rewrites old documents in place so a step can eventually be retired
+
+import (
+	"strings"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// step i upgrades a document from version i to i+1
+var courseSteps = []func(*types.Course){
+	// 0 -> 1: courses saved without sections had section_ids = null
+	func(c *types.Course) {
+		if c.SectionIDs == nil {
+			c.SectionIDs = []string{}
+		}
+	},
+}
+
+var sectionSteps = []func(*types.Section){
+	// 0 -> 1: sections without meetings had meetings = null.
+	// term can't be recovered here, prune only takes an empty term as the scraped one on single-term runs
+	func(s *types.Section) {
+		if s.Meetings == nil {
+			s.Meetings = []types.Meeting{}
+		}
+	},
+	// 1 -> 2: instructors list, older sections only had the professor's name.
+	// emails and co-instructors show up on the next scrape
+	func(s *types.Section) {
+		if len(s.Instructors) > 0 {
+			return
+		}
+		s.Instructors = []types.Instructor{}
+		if name := strings.TrimSpace(s.Professor); name != "" && name != "TBA" {
+			s.Instructors = append(s.Instructors, types.Instructor{
+				ID:      types.InstructorID(name, ""),
+				Name:    name,
+				Primary: true,
+			})
+		}
+	},
+	// 2 -> 3: crn field, older sections were keyed by the bare CRN
+	func(s *types.Section) {
+		if s.CRN == "" {
+			s.CRN = s.ID[strings.LastIndex(s.ID, "-")+1:]
+		}
+	},
+	// 3 -> 4: instructors with an email are indexed under their name too.
+	// nothing else changes, the rewrite is what updates instructor_ids and section_instructors
+	func(s *types.Section) {
+		s.InstructorIDs = types.InstructorKeys(s.Instructors)
+	},
+}
+
+var scheduleSteps = []func(*types.Schedule){
+	// 0 -> 1: sections = null on empty schedules, embedded sections get upgraded too
+	func(s *types.Schedule) {
+		if s.Sections == nil {
+			s.Sections = []types.Section{}
+		}
+		for i := range s.Sections {
+			UpgradeSection(&s.Sections[i])
+		}
+	},
+}
+
+// current version of each document type
+func CourseVersion() int   { return len(courseSteps) }
+func SectionVersion() int  { return len(sectionSteps) }
+func ScheduleVersion() int { return len(scheduleSteps) }
+
+// upgrade in place to the current version, reports whether anything was behind
+func UpgradeCourse(c *types.Course) bool {
+	return upgrade(c, &c.SchemaVersion, courseSteps)
+}
+
+func UpgradeSection(s *types.Section) bool {
+	s.StoredVersion = s.SchemaVersion
+	return upgrade(s, &s.SchemaVersion, sectionSteps)
+}
+
+func UpgradeSchedule(s *types.Schedule) bool {
+	return upgrade(s, &s.SchemaVersion, scheduleSteps)
+}
+
+// upgrade then force the current version, for writers
+// what we write always has this binary's shape, whatever version the input claimed
+func StampCourse(c *types.Course) {
+	UpgradeCourse(c)
+	c.SchemaVersion = CourseVersion()
+}
+
+func StampSection(s *types.Section) {
+	UpgradeSection(s)
+	s.SchemaVersion = SectionVersion()
+	s.StoredVersion = s.SchemaVersion
+}
+
+func StampSchedule(s *types.Schedule) {
+	UpgradeSchedule(s)
+	for i := range s.Sections {
+		StampSection(&s.Sections[i])
+	}
+	s.SchemaVersion = ScheduleVersion()
+}
+
+// documents from a newer version (written by a newer deploy) are left alone,
+// unknown fields are dropped by the decoder anyway
+func upgrade[T any](doc *T, version *int, steps []func(*T)) bool {
+	if *version >= len(steps) {
+		return false
+	}
+	for v := max(*version, 0); v < len(steps); v++ {
+		steps[v](doc)
+	}
+	*version = len(steps)
+	return true
+}
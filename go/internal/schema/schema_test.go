@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func TestUpgradeLegacyDocuments(t *testing.T) {
+	c := types.Course{ID: "CS110"}
+	if !UpgradeCourse(&c) {
+		t.Fatal("unversioned course should be upgraded")
+	}
+	if c.SectionIDs == nil || c.SchemaVersion != CourseVersion() {
+		t.Fatalf("unexpected course %+v", c)
+	}
+	if UpgradeCourse(&c) {
+		t.Fatal("current course should be left alone")
+	}
+
+	s := types.Schedule{ID: "s1", Sections: []types.Section{{ID: "10001"}}}
+	if !UpgradeSchedule(&s) {
+		t.Fatal("unversioned schedule should be upgraded")
+	}
+	if s.Sections[0].Meetings == nil || s.Sections[0].CRN != "10001" || s.Sections[0].SchemaVersion != SectionVersion() {
+		t.Fatalf("embedded section not upgraded: %+v", s.Sections[0])
+	}
+}
+
+func TestNewerDocumentsAreLeftAlone(t *testing.T) {
+	sec := types.Section{ID: "10001", SchemaVersion: SectionVersion() + 1}
+	if UpgradeSection(&sec) {
+		t.Fatal("a document from a newer version should not be touched")
+	}
+	if sec.Meetings != nil || sec.SchemaVersion != SectionVersion()+1 {
+		t.Fatalf("newer section was modified: %+v", sec)
+	}
+
+	// writers always stamp what they write with their own version
+	StampSection(&sec)
+	if sec.SchemaVersion != SectionVersion() {
+		t.Fatalf("stamp should set the current version, got %d", sec.SchemaVersion)
+	}
+}
+
+func TestSectionInstructorsFromProfessor(t *testing.T) {
+	sec := types.Section{ID: "10001", Professor: "Smith,  John", SchemaVersion: 1}
+	UpgradeSection(&sec)
+	if len(sec.Instructors) != 1 || sec.Instructors[0].ID != "name:smith, john" || !sec.Instructors[0].Primary {
+		t.Fatalf("unexpected instructors %+v", sec.Instructors)
+	}
+
+	tba := types.Section{ID: "10002", Professor: "TBA", SchemaVersion: 1}
+	UpgradeSection(&tba)
+	if tba.Instructors == nil || len(tba.Instructors) != 0 {
+		t.Fatalf("TBA should have an empty list, got %+v", tba.Instructors)
+	}
+}
+
+func TestSectionIndexedByInstructorName(t *testing.T) {
+	sec := types.Section{ID: "202610-10001", SchemaVersion: 3, Instructors: []types.Instructor{
+		{ID: "jsmith@gmu.edu", Name: "Smith, John", Email: "jsmith@gmu.edu"},
+	}}
+	if !UpgradeSection(&sec) {
+		t.Fatal("a version 3 section should be upgraded")
+	}
+	if fmt.Sprint(sec.InstructorIDs) != "[jsmith@gmu.edu name:smith, john]" {
+		t.Fatalf("unexpected instructor_ids %v", sec.InstructorIDs)
+	}
+}
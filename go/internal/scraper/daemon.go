@@ -0,0 +1,158 @@
+package scraper
+
+// daemon mode: scrape on a schedule until cancelled
+// runs come every Interval, and every FastInterval during registration windows,
+// when seats and waitlists move by the minute. every run is recorded in scrape_runs like any other
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+)
+
+const (
+	DefaultInterval     = 6 * time.Hour
+	DefaultFastInterval = 30 * time.Minute
+)
+
+// a stretch of time with faster polling ex) a registration period
+type Window struct {
+	Start time.Time
+	End   time.Time // exclusive
+}
+
+type Schedule struct {
+	Interval     time.Duration
+	FastInterval time.Duration
+	Windows      []Window // sorted by start
+}
+
+// SCRAPER_INTERVAL, SCRAPER_FAST_INTERVAL (durations) and SCRAPER_WINDOWS (see ParseWindows)
+// anything unset or unparsable gets the default, which is no windows
+func ScheduleFromEnv() Schedule {
+	s := Schedule{Interval: DefaultInterval, FastInterval: DefaultFastInterval}
+
+	if v := os.Getenv("SCRAPER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.Interval = d
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_INTERVAL=%q", v)
+		}
+	}
+	if v := os.Getenv("SCRAPER_FAST_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.FastInterval = d
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_FAST_INTERVAL=%q", v)
+		}
+	}
+	if v := os.Getenv("SCRAPER_WINDOWS"); v != "" {
+		if windows, err := ParseWindows(v); err == nil {
+			s.Windows = windows
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_WINDOWS: %v", err)
+		}
+	}
+	return s
+}
+
+// comma separated date ranges, both ends included, in local time
+// ex) "2026-03-30/2026-04-20,2026-10-26/2026-11-16"
+func ParseWindows(value string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(part, "/")
+		if !ok {
+			return nil, fmt.Errorf("window %q is not start/end", part)
+		}
+		start, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(from), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", part, err)
+		}
+		end, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(to), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", part, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("window %q ends before it starts", part)
+		}
+		// the whole last day counts
+		windows = append(windows, Window{Start: start, End: end.AddDate(0, 0, 1)})
+	}
+
+	slices.SortFunc(windows, func(a, b Window) int { return a.Start.Compare(b.Start) })
+	return windows, nil
+}
+
+// reports whether t falls in a registration window
+func (s Schedule) Fast(t time.Time) bool {
+	return slices.ContainsFunc(s.Windows, func(w Window) bool {
+		return !t.Before(w.Start) && t.Before(w.End)
+	})
+}
+
+// when the run after one started at last is due
+// a window opening before then pulls it in, so fast polling starts on time
+func (s Schedule) Next(last time.Time) time.Time {
+	interval := s.Interval
+	if s.Fast(last) {
+		interval = s.FastInterval
+	}
+	next := last.Add(interval)
+
+	for _, w := range s.Windows {
+		if w.Start.After(last) && w.Start.Before(next) {
+			return w.Start
+		}
+	}
+	return next
+}
+
+// scrapes on the schedule until ctx is cancelled, which is the only way it returns
+// a failed run is logged and recorded, and the next one goes ahead as planned.
+// a run already going (here or in another process) just skips that slot
+func Daemon(ctx context.Context, store storage.Store, opts Options, schedule Schedule) error {
+	log.Printf("scrape daemon: every %s, every %s in %d registration windows", schedule.Interval, schedule.FastInterval, len(schedule.Windows))
+
+	for {
+		started := time.Now()
+		run, err := Run(ctx, store, "daemon", opts)
+		switch {
+		case errors.Is(err, ErrAlreadyRunning):
+			log.Printf("scrape daemon: skipping, %v", err)
+		case run != nil:
+			log.Printf("scrape daemon: run %s %s in %s: %d added, %d changed, %d removed, %d errors",
+				run.ID, run.Status, run.FinishedAt.Sub(run.StartedAt).Round(time.Second),
+				run.Added, run.Changed, run.Removed, len(run.Errors))
+		case err != nil:
+			log.Printf("scrape daemon: run failed to start: %v", err)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// a run longer than the interval is followed by the next one right away
+		next := schedule.Next(started)
+		log.Printf("scrape daemon: next run at %s", next.Format(time.DateTime))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/bannermock"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func TestScheduleNext(t *testing.T) {
+	windows, err := ParseWindows("2026-10-26/2026-11-16, 2026-03-30/2026-04-20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 2 || windows[0].Start.Month() != time.March {
+		t.Fatalf("windows not sorted: %+v", windows)
+	}
+	s := Schedule{Interval: 6 * time.Hour, FastInterval: 30 * time.Minute, Windows: windows}
+
+	at := func(value string) time.Time {
+		t.Helper()
+		ts, err := time.ParseInLocation(time.DateTime, value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	tests := []struct {
+		last, want string
+	}{
+		{"2026-01-10 08:00:00", "2026-01-10 14:00:00"}, // normal interval
+		{"2026-03-30 00:10:00", "2026-03-30 00:40:00"}, // in a window
+		{"2026-04-20 23:50:00", "2026-04-21 00:20:00"}, // the last day counts
+		{"2026-04-21 00:10:00", "2026-04-21 06:10:00"}, // window is over
+		{"2026-10-25 20:00:00", "2026-10-26 00:00:00"}, // a window opening cuts the wait short
+	}
+	for _, tt := range tests {
+		if got := s.Next(at(tt.last)); !got.Equal(at(tt.want)) {
+			t.Errorf("Next(%s) = %s, want %s", tt.last, got.Format(time.DateTime), tt.want)
+		}
+	}
+
+	for _, bad := range []string{"2026-03-30", "2026-04-20/2026-03-30", "spring/fall"} {
+		if _, err := ParseWindows(bad); err == nil {
+			t.Errorf("ParseWindows(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRunRespectsStorageLock(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+
+	// another process is scraping
+	now := time.Now()
+	other := types.ScrapeLock{Holder: "elsewhere:1:abc", RunID: "r1", AcquiredAt: now, ExpiresAt: now.Add(time.Minute)}
+	if err := db.AcquireScrapeLock(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(ctx, db.Store(), "test", Options{ReplayDir: "testdata/spring"}); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("want ErrAlreadyRunning, got %v", err)
+	}
+
+	// once it's done, we can go, and the lock is given back afterwards
+	if err := db.ReleaseScrapeLock(ctx, other.Holder); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BANNER_BASE_URL", "")
+	replayRun(t, db, "spring")
+	if err := db.AcquireScrapeLock(ctx, other); err != nil {
+		t.Fatalf("lock not released after the run: %v", err)
+	}
+}
+
+func TestDaemonRecordsRuns(t *testing.T) {
+	srv := httptest.NewServer(bannermock.New(bannermock.Sample(), bannermock.Options{}))
+	defer srv.Close()
+	t.Setenv("BANNER_BASE_URL", srv.URL+bannermock.Prefix)
+
+	db := memory.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// stop once two runs are in the history
+	go func() {
+		for ctx.Err() == nil {
+			runs, _ := db.ListScrapeRuns(ctx, 10)
+			if len(runs) == 2 && runs[0].Status != types.ScrapeRunning {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	opts := Options{Terms: []string{"202610"}, Subjects: []string{"PHYS"}, Workers: 1, Rate: 1000}
+	err := Daemon(ctx, db.Store(), opts, Schedule{Interval: 50 * time.Millisecond, FastInterval: time.Millisecond})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want Canceled, got %v", err)
+	}
+
+	runs, err := db.ListScrapeRuns(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// newest first, a third run may have started before the cancel
+	if len(runs) < 2 {
+		t.Fatalf("want at least 2 runs, got %+v", runs)
+	}
+	first, second := runs[len(runs)-1], runs[len(runs)-2]
+	if first.Trigger != "daemon" || first.Status != types.ScrapeSucceeded || first.Added != 6 {
+		t.Fatalf("unexpected first run %+v", first)
+	}
+	// the second one found nothing new
+	if second.Status != types.ScrapeSucceeded || second.Unchanged != 6 || second.Added != 0 {
+		t.Fatalf("unexpected second run %+v", second)
+	}
+}
@@ -0,0 +1,131 @@
+package scraper
+
+// change detection
+// every parsed section is hashed and compared with the stored one, only new and changed
+// sections are written, and what changed ends up in the run's change log
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// fingerprint of everything we store about a section
+// meetings are sorted first, banner doesn't promise an order
+func hashSection(s types.Section) string {
+	s.Hash = ""
+	s.SchemaVersion = 0
+	s.Meetings = sortedMeetings(s.Meetings)
+
+	// can't fail, the section is plain data
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// the hash a stored section was written with
+// sections saved before hashing get theirs computed, so they aren't all "changed" once
+func storedHash(s types.Section) string {
+	if s.Hash != "" {
+		return s.Hash
+	}
+	return hashSection(s)
+}
+
+func sortedMeetings(meetings []types.Meeting) []types.Meeting {
+	if len(meetings) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(meetings)
+	slices.SortFunc(sorted, func(a, b types.Meeting) int {
+		return cmp.Or(
+			cmp.Compare(a.Day, b.Day),
+			cmp.Compare(a.StartTime, b.StartTime),
+			cmp.Compare(a.EndTime, b.EndTime),
+			cmp.Compare(a.Location, b.Location),
+		)
+	})
+	return sorted
+}
+
+// what changed between the stored section and the scraped one
+// nil if they're the same, one entry per kind otherwise
+func diffSection(stored, scraped types.Section) []types.SectionChange {
+	if storedHash(stored) == scraped.Hash {
+		return nil
+	}
+
+	change := func(kind, before, after string) types.SectionChange {
+		return types.SectionChange{
+			SectionID: scraped.ID,
+			CourseID:  scraped.CourseID,
+			Section:   scraped.Section,
+			Term:      scraped.Term,
+			Kind:      kind,
+			Before:    before,
+			After:     after,
+		}
+	}
+
+	var changes []types.SectionChange
+	if before, after := formatMeetings(stored.Meetings), formatMeetings(scraped.Meetings); before != after {
+		changes = append(changes, change(types.ChangeTime, before, after))
+	}
+	if before, after := formatInstructors(stored.Instructors), formatInstructors(scraped.Instructors); before != after {
+		changes = append(changes, change(types.ChangeInstructor, before, after))
+	}
+	// the hash moved for something else, location, the section number, an email
+	if len(changes) == 0 {
+		changes = append(changes, change(types.ChangeOther, "", ""))
+	}
+	return changes
+}
+
+// for added and removed sections
+func sectionChange(s types.Section, kind string) types.SectionChange {
+	return types.SectionChange{
+		SectionID: s.ID,
+		CourseID:  s.CourseID,
+		Section:   s.Section,
+		Term:      s.Term,
+		Kind:      kind,
+	}
+}
+
+var dayNames = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// days and times only ex) "Mon 10:30-11:45, Wed 10:30-11:45", "TBA" without meetings
+func formatMeetings(meetings []types.Meeting) string {
+	if len(meetings) == 0 {
+		return "TBA"
+	}
+
+	parts := make([]string, 0, len(meetings))
+	for _, m := range sortedMeetings(meetings) {
+		day := "?"
+		if m.Day >= 0 && m.Day < len(dayNames) {
+			day = dayNames[m.Day]
+		}
+		parts = append(parts, fmt.Sprintf("%s %d:%02d-%d:%02d", day, m.StartTime/60, m.StartTime%60, m.EndTime/60, m.EndTime%60))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// names only, in order ex) "Smith, John; Doe, Jane", "TBA" without instructors
+func formatInstructors(instructors []types.Instructor) string {
+	if len(instructors) == 0 {
+		return "TBA"
+	}
+
+	names := make([]string, len(instructors))
+	for i, in := range instructors {
+		names[i] = in.Name
+	}
+	return strings.Join(names, "; ")
+}
@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func testSection() types.Section {
+	return types.Section{
+		ID:        "10492",
+		CourseID:  "CS310",
+		Section:   "001",
+		Professor: "Goof",
+		Term:      "202610",
+		Instructors: []types.Instructor{
+			{ID: "goof@gmu.edu", Name: "Goof", Email: "goof@gmu.edu", Primary: true},
+		},
+		Meetings: []types.Meeting{
+			{Day: 3, StartTime: 630, EndTime: 705, Location: "Horizon 2008"},
+			{Day: 1, StartTime: 630, EndTime: 705, Location: "Horizon 2008"},
+		},
+	}
+}
+
+func TestHashIgnoresMeetingOrder(t *testing.T) {
+	a, b := testSection(), testSection()
+	b.Meetings[0], b.Meetings[1] = b.Meetings[1], b.Meetings[0]
+	b.SchemaVersion = 3
+
+	if hashSection(a) != hashSection(b) {
+		t.Fatal("same section hashed differently")
+	}
+
+	// a section stored before hashing is compared by its content
+	a.Hash = hashSection(a)
+	if changes := diffSection(b, a); changes != nil {
+		t.Fatalf("want no changes, got %+v", changes)
+	}
+}
+
+func TestDiffSection(t *testing.T) {
+	stored := testSection()
+	stored.Hash = hashSection(stored)
+
+	scraped := testSection()
+	scraped.Professor = "Prof Goof"
+	scraped.Instructors = append(scraped.Instructors, types.Instructor{ID: "name:max", Name: "Max"})
+	scraped.Instructors[0].Name = "Prof Goof"
+	scraped.Meetings[0].StartTime = 720
+	scraped.Meetings[0].EndTime = 795
+	scraped.Hash = hashSection(scraped)
+
+	changes := diffSection(stored, scraped)
+	if len(changes) != 2 {
+		t.Fatalf("want a time and an instructor change, got %+v", changes)
+	}
+	if c := changes[0]; c.Kind != types.ChangeTime || c.Before != "Mon 10:30-11:45, Wed 10:30-11:45" || c.After != "Mon 10:30-11:45, Wed 12:00-13:15" {
+		t.Fatalf("unexpected time change %+v", c)
+	}
+	if c := changes[1]; c.Kind != types.ChangeInstructor || c.Before != "Goof" || c.After != "Prof Goof; Max" {
+		t.Fatalf("unexpected instructor change %+v", c)
+	}
+
+	// only the room moved
+	scraped = testSection()
+	scraped.Meetings[1].Location = "Exploratory 1004"
+	scraped.Hash = hashSection(scraped)
+	if changes := diffSection(stored, scraped); len(changes) != 1 || changes[0].Kind != types.ChangeOther {
+		t.Fatalf("want one other change, got %+v", changes)
+	}
+
+	// a new email isn't an instructor change either
+	scraped = testSection()
+	scraped.Instructors[0].Email = "goof2@gmu.edu"
+	scraped.Hash = hashSection(scraped)
+	if changes := diffSection(stored, scraped); len(changes) != 1 || changes[0].Kind != types.ChangeOther {
+		t.Fatalf("want one other change for an email, got %+v", changes)
+	}
+}
@@ -0,0 +1,101 @@
+package scraper
+
+// the storage lock, so runs can't overlap across processes either
+// (the daemon, the admin API on another instance, someone running cmd/scraper by hand)
+// `running` only covers this process
+//
+// the lock is a lease: renewed every lockRenew while the run goes, and left to expire
+// if the process dies without releasing it
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+const (
+	lockTTL   = 2 * time.Minute
+	lockRenew = 30 * time.Second
+)
+
+// who this process is on the lock, the hostname and pid are for whoever has to read it
+var lockHolder = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%06x", host, os.Getpid(), rand.IntN(1<<24))
+}()
+
+func acquireLock(ctx context.Context, store storage.Store, runID string, acquiredAt time.Time) error {
+	now := time.Now().UTC()
+	err := store.ScrapeRuns.AcquireScrapeLock(ctx, types.ScrapeLock{
+		Holder:     lockHolder,
+		RunID:      runID,
+		AcquiredAt: acquiredAt,
+		ExpiresAt:  now.Add(lockTTL),
+	})
+
+	var locked *storage.LockedError
+	if errors.As(err, &locked) {
+		return fmt.Errorf("%w: lock %v", ErrAlreadyRunning, locked)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to take the scrape lock: %w", err)
+	}
+	return nil
+}
+
+// renews the lease until the returned release is called, which also gives the lock up
+// the context is cancelled if the lease is lost, someone else may be scraping by then
+func holdLock(ctx context.Context, store storage.Store, run *types.ScrapeRun) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRenew)
+		defer ticker.Stop()
+
+		// when the lease was last renewed, a few failed renewals in a row are fine until it runs out
+		renewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := acquireLock(ctx, store, run.ID, run.StartedAt)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrAlreadyRunning) || time.Since(renewed) >= lockTTL {
+				cancel(fmt.Errorf("lost the scrape lock: %w", err))
+				return
+			}
+			log.Printf("Warning: failed to renew the scrape lock for run %s: %v", run.ID, err)
+		}
+	}()
+
+	release := func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+		if err := store.ScrapeRuns.ReleaseScrapeLock(context.Background(), lockHolder); err != nil {
+			log.Printf("Warning: failed to release the scrape lock for run %s: %v", run.ID, err)
+		}
+	}
+	return ctx, release
+}
@@ -0,0 +1,209 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/banner"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+	"golang.org/x/time/rate"
+)
+
+// used when nothing else is configured
+const DefaultTerm = "202610" // spring 2026 term
+
+// in local we are only testing CS and MATH
+// use "all" to scrape every subject in prod
+var DefaultSubjects = []string{"CS", "MATH"}
+
+// keywords accepted in place of a list
+const (
+	AutoTerms   = "auto" // every term banner lists that's still open, see openTerms
+	AllSubjects = "all"  // every subject banner lists for the term
+)
+
+// how hard a scrape goes, used when nothing else is configured
+const (
+	DefaultWorkers = 8
+	DefaultRate    = 2.0 // banner requests per second
+	DefaultTimeout = time.Hour
+)
+
+// what a scrape covers, and how
+type Options struct {
+	// banner term codes ex) "202610", empty means AutoTerms
+	Terms []string `json:"terms"`
+	// subject codes ex) "CS", empty means AllSubjects
+	Subjects []string `json:"subjects"`
+
+	// goroutines writing courses to storage, 0 means DefaultWorkers
+	Workers int `json:"workers"`
+	// banner requests per second, 0 means DefaultRate
+	Rate float64 `json:"rate"`
+	// the whole run is cancelled after this, 0 means no limit
+	Timeout time.Duration `json:"timeout"`
+
+	// every banner page is also saved here as is, see saveRawPage. empty means don't
+	RawDir string `json:"-"`
+
+	// banner HTTP traffic is recorded to RecordDir, or answered from ReplayDir without
+	// touching the network, see banner.Recorder. both empty means talk to banner normally
+	RecordDir string `json:"-"`
+	ReplayDir string `json:"-"`
+}
+
+// parses comma separated lists like the -terms and -subjects flags take
+// "auto" / "all" (or an empty string) leave the list empty
+func ParseOptions(terms, subjects string) Options {
+	return Options{
+		Terms:    parseList(terms, AutoTerms),
+		Subjects: parseList(subjects, AllSubjects),
+	}
+}
+
+// SCRAPER_TERMS and SCRAPER_SUBJECTS, same format as the flags
+// unset falls back to DefaultTerm and DefaultSubjects
+// also SCRAPER_WORKERS, SCRAPER_RATE and SCRAPER_TIMEOUT (a duration ex) "90m"),
+// anything unset or unparsable gets the default.
+// SCRAPER_RECORD and SCRAPER_REPLAY are directories, see Options.RecordDir
+func OptionsFromEnv() Options {
+	terms, ok := os.LookupEnv("SCRAPER_TERMS")
+	if !ok {
+		terms = DefaultTerm
+	}
+	subjects, ok := os.LookupEnv("SCRAPER_SUBJECTS")
+	if !ok {
+		subjects = strings.Join(DefaultSubjects, ",")
+	}
+
+	opts := ParseOptions(terms, subjects)
+	opts.Workers = DefaultWorkers
+	opts.Rate = DefaultRate
+	opts.Timeout = DefaultTimeout
+
+	if v := os.Getenv("SCRAPER_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			opts.Workers = n
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_WORKERS=%q", v)
+		}
+	}
+	if v := os.Getenv("SCRAPER_RATE"); v != "" {
+		if r, err := strconv.ParseFloat(v, 64); err == nil && r > 0 {
+			opts.Rate = r
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_RATE=%q", v)
+		}
+	}
+	if v := os.Getenv("SCRAPER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			opts.Timeout = d
+		} else {
+			log.Printf("Warning: ignoring SCRAPER_TIMEOUT=%q", v)
+		}
+	}
+	opts.RecordDir = os.Getenv("SCRAPER_RECORD")
+	opts.ReplayDir = os.Getenv("SCRAPER_REPLAY")
+	return opts
+}
+
+func parseList(value, keyword string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if strings.EqualFold(v, keyword) {
+			return nil
+		}
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// a banner client for BANNER_BASE_URL (empty means GMU's), set up for the options'
+// request rate and recording or replaying
+func newClient(opts Options) (*banner.Client, error) {
+	client := banner.New(os.Getenv("BANNER_BASE_URL"))
+	if opts.Rate > 0 {
+		client.Limiter = rate.NewLimiter(rate.Limit(opts.Rate), banner.DefaultBurst)
+	}
+
+	switch {
+	case opts.ReplayDir != "" && opts.RecordDir != "":
+		return nil, errors.New("can't record and replay at the same time")
+	case opts.ReplayDir != "":
+		replayer, err := banner.NewReplayer(opts.ReplayDir)
+		if err != nil {
+			return nil, err
+		}
+		client.HTTPClient.Transport = replayer
+		// nobody to be polite to
+		client.Limiter = nil
+	case opts.RecordDir != "":
+		client.HTTPClient.Transport = banner.NewRecorder(opts.RecordDir, nil)
+	}
+	return client, nil
+}
+
+// every term banner lists, newest first, including the ones that are view only
+// only the record/replay options matter here
+func Terms(ctx context.Context, opts Options) ([]types.BannerTerm, error) {
+	client, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return client.Terms(ctx)
+}
+
+// terms we'd scrape with AutoTerms: banner marks past terms "(View Only)",
+// whatever is left is the current term and the upcoming ones
+func openTerms(terms []types.BannerTerm) []string {
+	var codes []string
+	for _, t := range terms {
+		if !strings.Contains(strings.ToLower(t.Description), "view only") {
+			codes = append(codes, t.Code)
+		}
+	}
+	return codes
+}
+
+// fills in AutoTerms from banner
+func resolveTerms(ctx context.Context, client *banner.Client, opts Options) ([]string, error) {
+	if len(opts.Terms) > 0 {
+		return opts.Terms, nil
+	}
+
+	terms, err := client.Terms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return openTerms(terms), nil
+}
+
+// fills in AllSubjects for the client's current term
+func resolveSubjects(ctx context.Context, client *banner.Client, opts Options) ([]string, error) {
+	if len(opts.Subjects) > 0 {
+		return opts.Subjects, nil
+	}
+
+	subjects, err := client.Subjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		codes = append(codes, s.Code)
+	}
+	return codes, nil
+}
@@ -0,0 +1,33 @@
+package scraper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts := ParseOptions(" 202610, 202620,202610", "cs,Math")
+	if fmt.Sprint(opts.Terms) != "[202610 202620]" || fmt.Sprint(opts.Subjects) != "[CS MATH]" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	// keywords and empty values both mean "ask banner"
+	for _, v := range []string{"", "auto", "AUTO"} {
+		if opts := ParseOptions(v, "all"); opts.Terms != nil || opts.Subjects != nil {
+			t.Fatalf("%q: want empty lists, got %+v", v, opts)
+		}
+	}
+}
+
+func TestOpenTermsSkipsViewOnly(t *testing.T) {
+	terms := []types.BannerTerm{
+		{Code: "202670", Description: "Fall 2026"},
+		{Code: "202640", Description: "Summer 2026"},
+		{Code: "202610", Description: "Spring 2026 (View Only)"},
+	}
+	if got := openTerms(terms); fmt.Sprint(got) != "[202670 202640]" {
+		t.Fatalf("unexpected open terms %v", got)
+	}
+}
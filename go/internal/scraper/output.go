@@ -0,0 +1,86 @@
+package scraper
+
+// scraping without touching production
+// a run can go into a memory store instead (empty, or a Snapshot of the real one for a dry run),
+// and Export writes that store out as files
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/banner"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/jsonl"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+)
+
+// file formats for Export
+const (
+	// fixture.json, what STORAGE_BACKEND=memory loads from STORAGE_FIXTURE
+	FormatJSON = "json"
+	// courses.jsonl and sections.jsonl, what cmd/backup imports
+	FormatJSONL = "jsonl"
+)
+
+// copies every course and section into a memory store
+// scraping into the copy shows what a real run would change, without writing anything
+func Snapshot(ctx context.Context, store storage.Store) (*memory.DB, error) {
+	courses, err := store.Courses.ListCourses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read courses: %w", err)
+	}
+	sections, err := store.Sections.ListSections(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sections: %w", err)
+	}
+
+	db := memory.New()
+	db.Seed(memory.Fixture{Courses: courses, Sections: sections})
+	return db, nil
+}
+
+// writes the store's courses and sections to dir in the given format
+func Export(ctx context.Context, store storage.Store, dir, format string) error {
+	courses, err := store.Courses.ListCourses(ctx)
+	if err != nil {
+		return err
+	}
+	sections, err := store.Sections.ListSections(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(memory.Fixture{Courses: courses, Sections: sections}, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "fixture.json"), data, 0644)
+
+	case FormatJSONL:
+		if err := jsonl.Write(filepath.Join(dir, "courses.jsonl"), courses); err != nil {
+			return err
+		}
+		return jsonl.Write(filepath.Join(dir, "sections.jsonl"), sections)
+
+	default:
+		return fmt.Errorf("unknown format %q, want %s or %s", format, FormatJSON, FormatJSONL)
+	}
+}
+
+// {dir}/{term}_{subject}_{offset}.json, the BannerResponse exactly as it came in
+func saveRawPage(dir, term, subject string, page *banner.Page) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%s_%04d.json", term, subject, page.Offset)
+	return os.WriteFile(filepath.Join(dir, name), page.Raw, 0644)
+}
@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+)
+
+// a fixed number of goroutines doing the storage writes
+// banner hands us a page at a time and a big subject can have dozens of courses per page,
+// so without a cap every course of every page would be its own goroutine hammering firestore
+type writePool struct {
+	jobs chan func(context.Context)
+	// jobs that are queued or running, see wait
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+// n workers, the queue holds another n jobs before submit blocks
+func newWritePool(ctx context.Context, n int) *writePool {
+	if n <= 0 {
+		n = DefaultWorkers
+	}
+
+	p := &writePool{jobs: make(chan func(context.Context), n)}
+	for range n {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for job := range p.jobs {
+				// once cancelled, queued jobs are dropped, but a running write
+				// is allowed to finish so no course is left half reported
+				if ctx.Err() == nil {
+					job(context.WithoutCancel(ctx))
+				}
+				p.pending.Done()
+			}
+		}()
+	}
+	return p
+}
+
+// queues a job, blocking while the queue is full
+// returns false if ctx was cancelled first, the job won't run then
+func (p *writePool) submit(ctx context.Context, job func(context.Context)) bool {
+	p.pending.Add(1)
+	select {
+	case p.jobs <- job:
+		return true
+	case <-ctx.Done():
+		p.pending.Done()
+		return false
+	}
+}
+
+// blocks until everything submitted so far is done (or dropped)
+func (p *writePool) wait() {
+	p.pending.Wait()
+}
+
+// waits for the queue to drain and stops the workers, the pool can't be used after
+func (p *writePool) close() {
+	close(p.jobs)
+	p.workers.Wait()
+}
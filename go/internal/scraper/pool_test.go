@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWritePoolCapsConcurrency(t *testing.T) {
+	ctx := context.Background()
+	pool := newWritePool(ctx, 3)
+	defer pool.close()
+
+	var running, peak, done atomic.Int32
+	for range 20 {
+		pool.submit(ctx, func(context.Context) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	pool.wait()
+
+	if done.Load() != 20 || peak.Load() > 3 {
+		t.Fatalf("want 20 jobs on at most 3 workers, got %d jobs and %d at once", done.Load(), peak.Load())
+	}
+}
+
+func TestWritePoolDropsQueuedJobsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := newWritePool(ctx, 1)
+
+	// the first job holds the only worker until we cancel
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var ran atomic.Int32
+	pool.submit(ctx, func(ctx context.Context) {
+		close(started)
+		<-release
+		if ctx.Err() != nil {
+			t.Error("a running write shouldn't see the cancel")
+		}
+		ran.Add(1)
+	})
+	<-started
+	pool.submit(ctx, func(context.Context) { ran.Add(1) })
+
+	cancel()
+	if pool.submit(ctx, func(context.Context) { ran.Add(1) }) {
+		t.Error("submit after cancel should refuse the job")
+	}
+	close(release)
+	pool.close()
+
+	if ran.Load() != 1 {
+		t.Fatalf("want only the running job to finish, %d ran", ran.Load())
+	}
+}
@@ -0,0 +1,713 @@
+package scraper
+
+// scraper for GMU courses
+// performs guest handshake to get session cookie + synchronizer token
+// then fetches course data for the configured terms and subjects (see Options)
+// and saves the parsed courses/sections to storage
+//
+// lives in its own package so both cmd/scraper and the admin API can run it
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/banner"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// returned when a run is requested while another one is in progress
+var ErrAlreadyRunning = errors.New("a scrape is already running")
+
+// only one scrape per process at a time
+// banner sessions are stateful and two runs would step on each other
+var running atomic.Bool
+
+// runs a full scrape and blocks until it's done
+// trigger records who started it ex) "cli"
+func Run(ctx context.Context, store storage.Store, trigger string, opts Options) (*types.ScrapeRun, error) {
+	if !running.CompareAndSwap(false, true) {
+		return nil, ErrAlreadyRunning
+	}
+	defer running.Store(false)
+
+	run := newRun(trigger, opts)
+	if err := acquireLock(ctx, store, run.ID, run.StartedAt); err != nil {
+		return nil, err
+	}
+	err := execute(ctx, store, run, opts)
+	return run, err
+}
+
+// starts a scrape in the background and returns the run record right away
+// used by the admin API so the request doesn't hang for minutes
+func Start(store storage.Store, trigger string, opts Options) (*types.ScrapeRun, error) {
+	if !running.CompareAndSwap(false, true) {
+		return nil, ErrAlreadyRunning
+	}
+
+	run := newRun(trigger, opts)
+	// taken here so the caller hears about a run in another process right away
+	if err := acquireLock(context.Background(), store, run.ID, run.StartedAt); err != nil {
+		running.Store(false)
+		return nil, err
+	}
+	snapshot := *run
+
+	go func() {
+		defer running.Store(false)
+		// request context is long gone by now, so use background
+		if err := execute(context.Background(), store, run, opts); err != nil {
+			log.Printf("scrape %s failed: %v", run.ID, err)
+		}
+	}()
+
+	return &snapshot, nil
+}
+
+// reports whether a scrape is in progress in this process
+func Running() bool {
+	return running.Load()
+}
+
+// start of the last run, only touched while holding running
+var lastStart time.Time
+
+func newRun(trigger string, opts Options) *types.ScrapeRun {
+	// the start time is the ID, so back to back runs (replays, tests) can't share one
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(lastStart) {
+		now = lastStart.Add(time.Millisecond)
+	}
+	lastStart = now
+
+	return &types.ScrapeRun{
+		ID:        now.Format("20060102T150405.000Z"),
+		Trigger:   trigger,
+		Terms:     slices.Clone(opts.Terms),
+		Subjects:  slices.Clone(opts.Subjects),
+		Status:    types.ScrapeRunning,
+		StartedAt: now,
+		Errors:    []string{},
+	}
+}
+
+// records the run, scrapes, then records the outcome
+// the caller has taken the storage lock, it's held until the final record is written
+func execute(ctx context.Context, store storage.Store, run *types.ScrapeRun, opts Options) error {
+	ctx, release := holdLock(ctx, store, run)
+	defer release()
+
+	if err := store.ScrapeRuns.SaveScrapeRun(ctx, *run); err != nil {
+		log.Printf("Warning: failed to record scrape run %s: %v", run.ID, err)
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	changes, err := scrape(ctx, store, run, opts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", opts.Timeout, err)
+	}
+	// say why, if it wasn't just ctrl-c (a lost lock)
+	if errors.Is(err, context.Canceled) {
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+			err = cause
+		}
+	}
+
+	run.FinishedAt = time.Now().UTC()
+	run.Status = types.ScrapeSucceeded
+
+	// what failed is already in run.Errors one by one
+	var partial *partialError
+	if errors.As(err, &partial) {
+		run.Status = types.ScrapePartial
+		err = nil
+	}
+	if err != nil {
+		run.Status = types.ScrapeFailed
+		run.Errors = append(run.Errors, err.Error())
+	}
+
+	// whatever made it to storage is logged, even when the run failed halfway
+	if len(changes) > 0 {
+		if serr := store.ScrapeRuns.SaveScrapeChanges(context.Background(), run.ID, changes); serr != nil {
+			log.Printf("Warning: failed to save change log of scrape run %s: %v", run.ID, serr)
+			run.Errors = append(run.Errors, fmt.Sprintf("change log not saved: %v", serr))
+		}
+	}
+
+	// background context so a cancelled run still gets its final record
+	if serr := store.ScrapeRuns.SaveScrapeRun(context.Background(), *run); serr != nil {
+		log.Printf("Warning: failed to record scrape run %s: %v", run.ID, serr)
+	}
+	return err
+}
+
+// shared by every term of a run
+type progress struct {
+	store storage.Store
+	run   *types.ScrapeRun
+
+	// storage writes, so banner paging doesn't wait on firestore
+	pool *writePool
+
+	// stored course metadata, so a course is only rewritten when it or its sections changed
+	// only touched by the scraping loop, not the writers
+	courses map[string]types.Course
+
+	// courses that made it to storage, for the run record
+	courseMu     sync.Mutex
+	savedCourses map[string]bool
+
+	// change log entries of everything that was written or pruned
+	changeMu  sync.Mutex
+	changes   []types.SectionChange
+	unchanged int
+
+	// counters and errors for the run record
+	sectionCount atomic.Int64
+	errMu        sync.Mutex
+
+	// what couldn't be scraped, "202610/CS" for a subject or "202610" for a whole term
+	failed []string
+	// subjects that went through, so a run where everything failed isn't called partial
+	succeeded int
+}
+
+// some subjects failed, everything else was scraped and pruned normally
+type partialError struct {
+	failed []string
+}
+
+func (e *partialError) Error() string {
+	return fmt.Sprintf("%d subjects failed: %s", len(e.failed), strings.Join(e.failed, ", "))
+}
+
+func (p *progress) recordErr(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	p.errMu.Lock()
+	p.run.Errors = append(p.run.Errors, msg)
+	p.errMu.Unlock()
+}
+
+// returns the change log along with the error, it covers what was written before things went wrong
+func scrape(ctx context.Context, store storage.Store, run *types.ScrapeRun, opts Options) (changes []types.SectionChange, err error) {
+	client, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// guest handshake to get X-Synchronizer-Token
+	if err := client.Handshake(ctx); err != nil {
+		return nil, err
+	}
+
+	terms, err := resolveTerms(ctx, client, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up terms: %w", err)
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("banner lists no open terms")
+	}
+	run.Terms = terms
+
+	// ~5000 docs, same read the catalog cache does on startup
+	courses, err := store.Courses.ListCourses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored courses: %w", err)
+	}
+
+	p := &progress{
+		store:        store,
+		run:          run,
+		pool:         newWritePool(ctx, opts.Workers),
+		courses:      make(map[string]types.Course, len(courses)),
+		savedCourses: make(map[string]bool),
+	}
+	for _, c := range courses {
+		p.courses[c.ID] = c
+	}
+
+	// stop the workers, even when bailing out early
+	// so nothing writes to the run record (or the change log) after we return
+	defer func() {
+		p.pool.close()
+		p.count()
+		changes = p.changes
+	}()
+
+	var allSubjects []string
+	for _, term := range terms {
+		subjects, err := scrapeTerm(ctx, client, p, term, opts)
+		for _, subj := range subjects {
+			if !slices.Contains(allSubjects, subj) {
+				allSubjects = append(allSubjects, subj)
+			}
+		}
+		run.Subjects = allSubjects
+
+		// a cancelled run stops here, a term that failed on its own is skipped
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			p.recordErr("term %s failed: %v", term, err)
+			p.failed = append(p.failed, term)
+		}
+	}
+
+	// the change log is filled in on the way out, see the defer above
+	if len(p.failed) == 0 {
+		return nil, nil
+	}
+	if p.succeeded == 0 {
+		return nil, errors.New("nothing could be scraped")
+	}
+	return nil, &partialError{failed: p.failed}
+}
+
+// fills in the run record's counters, once every writer is done
+func (p *progress) count() {
+	p.run.Courses = len(p.savedCourses)
+	p.run.Sections = int(p.sectionCount.Load())
+	p.run.Unchanged = p.unchanged
+
+	changed := make(map[string]bool)
+	p.run.Added, p.run.Removed = 0, 0
+	for _, c := range p.changes {
+		switch c.Kind {
+		case types.ChangeAdded:
+			p.run.Added++
+		case types.ChangeRemoved:
+			p.run.Removed++
+		default:
+			changed[c.SectionID] = true
+		}
+	}
+	p.run.Changed = len(changed)
+}
+
+// the changes of the sections that were actually saved
+// a section that failed to write shows up as changed again next run
+func (p *progress) logChanges(changes []types.SectionChange, saved []types.Section) {
+	ids := make(map[string]bool, len(saved))
+	for _, s := range saved {
+		ids[s.ID] = true
+	}
+
+	p.changeMu.Lock()
+	defer p.changeMu.Unlock()
+	for _, c := range changes {
+		if ids[c.SectionID] {
+			p.changes = append(p.changes, c)
+		}
+	}
+}
+
+// scrapes and prunes one term, returns the subjects it covered
+// a subject that fails is recorded and skipped (and not pruned), the error is only for the term itself
+func scrapeTerm(ctx context.Context, client *banner.Client, p *progress, term string, opts Options) ([]string, error) {
+	// setting the term
+	// all requests will happen after setting the term
+	if err := client.SetTerm(ctx, term); err != nil {
+		return nil, err
+	}
+
+	subjects, err := resolveSubjects(ctx, client, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up subjects: %w", err)
+	}
+
+	// what's there now, to compare against
+	storedSections, err := p.store.Sections.ListSections(ctx, term)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored sections: %w", err)
+	}
+	stored := make(map[string]types.Section, len(storedSections))
+	for _, s := range storedSections {
+		stored[s.ID] = s
+	}
+
+	// search for classes
+	// this runs inside the server too, so it logs once per term, not per page or section
+	log.Printf("scrape: fetching %s for %d subjects", term, len(subjects))
+
+	// every section banner listed and every subject that returned anything, for pruning
+	seen := make(map[string]bool)
+	var scraped []string
+
+	for _, subj := range subjects {
+		// NOTE: banner api is stateful, the iterator resets the search
+		// before its first page so subjects don't pile up
+		pages := client.SearchSections(ctx, subj)
+
+		var subjErr error
+		for {
+			page, err := pages.Next()
+			if err == banner.Done {
+				break
+			}
+			if err != nil {
+				subjErr = err
+				break
+			}
+
+			if opts.RawDir != "" {
+				if err := saveRawPage(opts.RawDir, term, subj, page); err != nil {
+					log.Printf("Warning: failed to save raw page: %v", err)
+				}
+			}
+
+			// NOTE: we save course and section data separately
+			// in different root collection in firestore.
+			// this is to avoid data being 1mb< and hitting firebase document limit
+			// also it allows us to use lazy loading for courses
+			// fetch courses -> fetch sections on demand
+
+			// + why not subcollection?
+			// query limitation. to use collection group queries,
+			// we cannot have subcollections:
+			// fs.Get("10492") is logically sound than fs.Get("courses/CS110/sections/10492")
+			// when we want extra search features later
+			// "give me all sections taught by prof goof" for example.
+
+			// group the page by course so each course and its sections
+			// go out in one atomic write. the course is created if it's missing,
+			// so a section can't be saved without its link anymore
+			var order []string
+			courses := make(map[string]types.Course)
+			grouped := make(map[string][]types.Section)
+
+			if !slices.Contains(scraped, subj) {
+				scraped = append(scraped, subj)
+			}
+
+			for _, rawSec := range page.Sections {
+				cleanSec := parseBannerSection(rawSec, term)
+				cleanSec.Hash = hashSection(cleanSec)
+				seen[cleanSec.ID] = true
+
+				if _, ok := courses[cleanSec.CourseID]; !ok {
+					order = append(order, cleanSec.CourseID)
+					courses[cleanSec.CourseID] = types.Course{
+						ID:         cleanSec.CourseID,
+						Department: rawSec.Subject,
+						Code:       rawSec.CourseNumber,
+						Title:      rawSec.Title,
+					}
+				}
+				grouped[cleanSec.CourseID] = append(grouped[cleanSec.CourseID], cleanSec)
+			}
+
+			for _, courseID := range order {
+				c := courses[courseID]
+				sections, changes := p.diff(grouped[courseID], stored)
+
+				// nothing new about the course or any of its sections, skip the write
+				known, ok := p.courses[courseID]
+				if len(sections) == 0 && ok && sameCourse(known, c) {
+					continue
+				}
+				p.courses[courseID] = c
+
+				p.pool.submit(ctx, func(ctx context.Context) {
+					saved, courseSaved := saveCourseSections(ctx, p.store, c, sections, p.recordErr)
+					p.logChanges(changes, saved)
+					if courseSaved {
+						p.courseMu.Lock()
+						p.savedCourses[c.ID] = true
+						p.courseMu.Unlock()
+					}
+
+					p.sectionCount.Add(int64(len(saved)))
+				})
+			}
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			return subjects, ctx.Err()
+
+		case subjErr != nil:
+			// retries and session renewal already happened in the client, so this one is really broken.
+			// whatever pages made it are saved, but it's not pruned: banner may list more than we saw
+			p.recordErr("subject %s in %s failed: %v", subj, term, subjErr)
+			p.failed = append(p.failed, term+"/"+subj)
+			scraped = slices.DeleteFunc(scraped, func(s string) bool { return s == subj })
+
+		case !slices.Contains(scraped, subj):
+			// no classes found, skip to next subject
+			p.succeeded++
+
+		default:
+			p.succeeded++
+		}
+	}
+
+	// everything must be written before we decide what's stale
+	p.pool.wait()
+	log.Printf("scrape: %s lists %d sections in %d of %d subjects", term, len(seen), len(scraped), len(subjects))
+
+	legacy := len(p.run.Terms) == 1
+	summary, err := prune(ctx, p.store, term, subjects, seen, scraped, legacy)
+	for _, id := range summary.DeletedSections {
+		s, ok := stored[id]
+		if !ok {
+			// no term on it, so it wasn't in the term's listing
+			s = types.Section{ID: id}
+		}
+		p.changes = append(p.changes, sectionChange(s, types.ChangeRemoved))
+	}
+	return subjects, err
+}
+
+// splits a course's scraped sections into the ones that need writing, with their change log entries
+func (p *progress) diff(sections []types.Section, stored map[string]types.Section) ([]types.Section, []types.SectionChange) {
+	var write []types.Section
+	var changes []types.SectionChange
+	for _, s := range sections {
+		old, ok := stored[s.ID]
+		if !ok {
+			write = append(write, s)
+			changes = append(changes, sectionChange(s, types.ChangeAdded))
+			continue
+		}
+
+		diff := diffSection(old, s)
+		if diff == nil && old.StoredVersion < schema.SectionVersion() {
+			// same data in an older shape, rewritten so what's derived from it (the instructor index) catches up
+			write = append(write, s)
+			continue
+		}
+		if diff == nil {
+			p.unchanged++
+			continue
+		}
+		write = append(write, s)
+		changes = append(changes, diff...)
+	}
+	return write, changes
+}
+
+// only what the scraper sets, description and credits don't come from the search
+func sameCourse(a, b types.Course) bool {
+	return a.Department == b.Department && a.Code == b.Code && a.Title == b.Title
+}
+
+// removes what banner no longer lists, only for subjects that returned results
+// a subject that came back empty is more likely a banner hiccup than every class being cancelled.
+// sections with an empty term (stored before terms were, see migration 0002) are read as this term's
+// only when legacy is set, i.e. the run covers a single term. with more terms they can't be told apart
+// from another term's, so they're kept until a single-term run
+func prune(ctx context.Context, store storage.Store, term string, subjects []string, seen map[string]bool, scraped []string, legacy bool) (storage.PruneSummary, error) {
+	for _, subj := range subjects {
+		if !slices.Contains(scraped, subj) {
+			log.Printf("prune: %s returned no sections in %s, leaving it untouched", subj, term)
+		}
+	}
+	if len(scraped) == 0 {
+		return storage.PruneSummary{}, nil
+	}
+
+	summary, err := store.Sections.PruneSections(ctx, term, scraped, seen, legacy)
+	if err != nil {
+		return summary, fmt.Errorf("prune failed: %w", err)
+	}
+
+	log.Printf("prune %s %v: deleted %d sections, removed %d dangling section_ids, deleted %d empty courses",
+		term, scraped, len(summary.DeletedSections), summary.RemovedLinks, len(summary.DeletedCourses))
+	if len(summary.DeletedSections) > 0 {
+		log.Printf("   > deleted sections: %s", strings.Join(summary.DeletedSections, ", "))
+	}
+	if len(summary.DeletedCourses) > 0 {
+		log.Printf("   > deleted courses: %s", strings.Join(summary.DeletedCourses, ", "))
+	}
+	return summary, nil
+}
+
+// writes one course with its sections and records every document that failed
+// returns the sections that were saved and whether the course itself was
+func saveCourseSections(ctx context.Context, store storage.Store, course types.Course, sections []types.Section, recordErr func(string, ...any)) ([]types.Section, bool) {
+	err := store.Sections.SaveCourseSections(ctx, course, sections)
+	if err == nil {
+		return sections, true
+	}
+
+	var batchErr *storage.BatchError
+	if !errors.As(err, &batchErr) {
+		recordErr("Error saving course %s with %d sections: %v", course.ID, len(sections), err)
+		return nil, false
+	}
+
+	failed := make(map[string]bool)
+	for _, f := range batchErr.Failures {
+		recordErr("Error saving %s/%s: %v", f.Collection, f.ID, f.Err)
+		failed[f.Collection+"/"+f.ID] = true
+	}
+
+	var saved []types.Section
+	for _, s := range sections {
+		if !failed["sections/"+s.ID] {
+			saved = append(saved, s)
+		}
+	}
+	return saved, !failed["courses/"+course.ID]
+}
+
+// parsing time: 1330 -> 13 + 30
+// return 0 if nil or invalid
+func parseTimeStr(t *string) int {
+	if t == nil {
+		return 0
+	}
+	val := *t
+	if len(val) < 4 {
+		return 0
+	}
+	// "1330" -> 13, 30
+	hh, _ := strconv.Atoi(val[:2])
+	mm, _ := strconv.Atoi(val[2:])
+	return (hh * 60) + mm
+}
+
+// safely dereference string pointer
+func getStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// everyone on the section's faculty list, then anyone only listed on a meeting
+// (a lab taught by someone else), primary instructor first, otherwise in banner's order
+func parseInstructors(raw types.BannerSection) []types.Instructor {
+	instructors := []types.Instructor{}
+	// ID and name -> index, meeting faculty sometimes comes without the email
+	seen := make(map[string]int)
+
+	add := func(name, email string, primary bool) {
+		name = strings.TrimSpace(name)
+		id := types.InstructorID(name, email)
+		if id == "" {
+			return
+		}
+		byName := types.InstructorID(name, "")
+		i, ok := seen[id]
+		if !ok && byName != "" {
+			i, ok = seen[byName]
+		}
+		if ok {
+			instructors[i].Primary = instructors[i].Primary || primary
+			return
+		}
+		seen[id] = len(instructors)
+		if byName != "" {
+			seen[byName] = len(instructors)
+		}
+		instructors = append(instructors, types.Instructor{
+			ID:      id,
+			Name:    name,
+			Email:   strings.TrimSpace(email),
+			Primary: primary,
+		})
+	}
+
+	for _, f := range raw.Faculty {
+		add(f.DisplayName, f.Email, f.Primary)
+	}
+	for _, mf := range raw.MeetingsFaculty {
+		for _, f := range mf.Faculty {
+			add(f.DisplayName, f.Email, false)
+		}
+	}
+
+	// stable, so the rest keep banner's order and the hash doesn't move
+	slices.SortStableFunc(instructors, func(a, b types.Instructor) int {
+		switch {
+		case a.Primary == b.Primary:
+			return 0
+		case a.Primary:
+			return -1
+		default:
+			return 1
+		}
+	})
+	return instructors
+}
+
+// parse into section type
+// term is the one being scraped, used when banner leaves it out
+func parseBannerSection(raw types.BannerSection, term string) types.Section {
+	sec := types.Section{
+		CourseID: raw.Subject + raw.CourseNumber, // ex) CS100
+		Section:  raw.SequenceNumber,
+		Term:     raw.Term,
+		CRN:      raw.CRN,
+
+		Instructors: parseInstructors(raw),
+		Professor:   "TBA",
+	}
+
+	// banner always sends it, but prune relies on it so don't trust that
+	if sec.Term == "" {
+		sec.Term = term
+	}
+	sec.ID = types.SectionID(sec.Term, sec.CRN)
+
+	if len(sec.Instructors) > 0 {
+		sec.Professor = sec.Instructors[0].Name
+	}
+
+	// parse meetings
+	for _, mf := range raw.MeetingsFaculty {
+		mt := mf.MeetingTime
+
+		if mt.BeginTime == nil || mt.EndTime == nil {
+			continue
+		}
+
+		// converting 1000 -> 600 minutes
+		startMin := parseTimeStr(mt.BeginTime)
+		endMin := parseTimeStr(mt.EndTime)
+
+		// handle potential null location
+		loc := getStr(mt.Building) + " " + getStr(mt.Room)
+		if strings.TrimSpace(loc) == "" {
+			loc = "Online / TBA"
+		}
+
+		// banner stores days as booleans
+		// need to create a meeting for EACH true day, in day order so the hash is stable
+		days := [7]bool{
+			mt.Sunday, mt.Monday, mt.Tuesday,
+			mt.Wednesday, mt.Thursday, mt.Friday, mt.Saturday,
+		}
+
+		for dayCode, isActive := range days {
+			if isActive {
+				sec.Meetings = append(sec.Meetings, types.Meeting{
+					Day:       dayCode,
+					StartTime: startMin,
+					EndTime:   endMin,
+					Location:  loc,
+				})
+			}
+		}
+	}
+	return sec
+}
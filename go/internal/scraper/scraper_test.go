@@ -0,0 +1,244 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/bannermock"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage/memory"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// a full run against recorded banner traffic, see testdata
+func replayRun(t *testing.T, db *memory.DB, fixture string) *types.ScrapeRun {
+	t.Helper()
+	opts := Options{
+		Terms:     []string{"202610"},
+		Subjects:  []string{"CS", "MATH"},
+		Workers:   2,
+		ReplayDir: "testdata/" + fixture,
+	}
+	run, err := Run(context.Background(), db.Store(), "test", opts)
+	if err != nil {
+		t.Fatalf("%s: %v", fixture, err)
+	}
+	if run.Status != types.ScrapeSucceeded {
+		t.Fatalf("%s: run %s, errors %v", fixture, run.Status, run.Errors)
+	}
+	return run
+}
+
+func TestScrapeFromRecording(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("BANNER_BASE_URL", "")
+	db := memory.New()
+
+	run := replayRun(t, db, "spring")
+	if run.Courses != 2 || run.Sections != 3 || run.Added != 3 {
+		t.Fatalf("first run: %+v", run)
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].Professor != "Goof" || formatMeetings(sections[0].Meetings) != "Mon 10:30-11:45, Wed 10:30-11:45" {
+		t.Fatalf("unexpected CS310 sections %+v", sections)
+	}
+	if sections[0].Meetings[0].Location != "Horizon Hall 2008" || sections[0].Hash == "" {
+		t.Fatalf("section not normalized %+v", sections[0])
+	}
+
+	// same data again, nothing gets written
+	run = replayRun(t, db, "spring")
+	if run.Courses != 0 || run.Sections != 0 || run.Unchanged != 3 || run.Added+run.Changed+run.Removed != 0 {
+		t.Fatalf("unchanged run: %+v", run)
+	}
+
+	// a new time, a new instructor, and a section replaced by another one
+	run = replayRun(t, db, "spring-updated")
+	if run.Sections != 3 || run.Added != 1 || run.Changed != 2 || run.Removed != 1 {
+		t.Fatalf("updated run: %+v", run)
+	}
+
+	changes, err := db.ListScrapeChanges(ctx, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s %s %s->%s", c.SectionID, c.Kind, c.Before, c.After))
+	}
+	want := "[202610-10492 time_changed Mon 10:30-11:45, Wed 10:30-11:45->Mon 12:00-13:15, Wed 12:00-13:15 " +
+		"202610-10493 instructor_changed Smith->Jones " +
+		"202610-20001 removed -> " +
+		"202610-20002 added ->]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("change log\n got %v\nwant %s", got, want)
+	}
+}
+
+// sections stored by an older version are rewritten even when banner has nothing new for them
+func TestScrapeRewritesOutdatedSections(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("BANNER_BASE_URL", "")
+	db := memory.New()
+	replayRun(t, db, "spring")
+
+	// as if the last scrape was done before the current schema version
+	sections, err := db.ListSections(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range sections {
+		sections[i].SchemaVersion = 1
+	}
+	db.Seed(memory.Fixture{Sections: sections})
+
+	run := replayRun(t, db, "spring")
+	if run.Sections != 3 || run.Unchanged != 0 || run.Added+run.Changed+run.Removed != 0 {
+		t.Fatalf("outdated run: %+v", run)
+	}
+	if changes, err := db.ListScrapeChanges(ctx, run.ID); err != nil || len(changes) != 0 {
+		t.Fatalf("a rewrite isn't a change: %+v %v", changes, err)
+	}
+
+	run = replayRun(t, db, "spring")
+	if run.Sections != 0 || run.Unchanged != 3 {
+		t.Fatalf("run after the rewrite: %+v", run)
+	}
+}
+
+// a live run against the mock, with more sections than fit on a page
+func TestScrapeAgainstMock(t *testing.T) {
+	srv := httptest.NewServer(bannermock.New(bannermock.Sample(), bannermock.Options{}))
+	defer srv.Close()
+	t.Setenv("BANNER_BASE_URL", srv.URL+bannermock.Prefix)
+
+	db := memory.New()
+	opts := Options{Terms: []string{"202610"}, Subjects: []string{"CS", "PHYS"}, Workers: 2, Rate: 1000}
+	run, err := Run(context.Background(), db.Store(), "test", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != types.ScrapeSucceeded || run.Courses != 5 || run.Sections != 66 || run.Added != 66 {
+		t.Fatalf("run %+v", run)
+	}
+}
+
+func TestParseInstructors(t *testing.T) {
+	// banner's shape, the lab instructor is only on the second meeting and the primary one
+	// shows up there again without an email
+	data := `{
+		"courseReferenceNumber": "10492", "subject": "CS", "courseNumber": "310", "sequenceNumber": "001",
+		"faculty": [
+			{"displayName": "Doe, Jane", "emailAddress": "jdoe@gmu.edu", "primaryIndicator": false},
+			{"displayName": "Smith, John", "emailAddress": "JSmith@gmu.edu", "primaryIndicator": true}
+		],
+		"meetingsFaculty": [
+			{"meetingTime": {}, "faculty": []},
+			{"meetingTime": {}, "faculty": [
+				{"displayName": "Lee, Ann", "email": ""},
+				{"displayName": "Smith,  John", "email": ""}
+			]}
+		]
+	}`
+	var raw types.BannerSection
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	sec := parseBannerSection(raw, "202610")
+	var got []string
+	for _, in := range sec.Instructors {
+		got = append(got, fmt.Sprintf("%s|%s|%s|%v", in.ID, in.Name, in.Email, in.Primary))
+	}
+	want := "[jsmith@gmu.edu|Smith, John|JSmith@gmu.edu|true " +
+		"jdoe@gmu.edu|Doe, Jane|jdoe@gmu.edu|false " +
+		"name:lee, ann|Lee, Ann||false]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("instructors\n got %v\nwant %s", got, want)
+	}
+	if sec.Professor != "Smith, John" {
+		t.Fatalf("professor = %q", sec.Professor)
+	}
+
+	if sec := parseBannerSection(types.BannerSection{CRN: "1"}, "202610"); sec.Professor != "TBA" || sec.Instructors == nil || len(sec.Instructors) != 0 {
+		t.Fatalf("want TBA and no instructors, got %q %+v", sec.Professor, sec.Instructors)
+	}
+}
+
+// banner reuses CRNs across terms, the sample does too
+func TestSameCRNInTwoTerms(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(bannermock.New(bannermock.Sample(), bannermock.Options{}))
+	defer srv.Close()
+	t.Setenv("BANNER_BASE_URL", srv.URL+bannermock.Prefix)
+
+	db := memory.New()
+	opts := Options{Terms: []string{"202670", "202610"}, Subjects: []string{"PHYS"}, Workers: 2, Rate: 1000}
+	run, err := Run(ctx, db.Store(), "test", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Sections != 12 || run.Added != 12 {
+		t.Fatalf("run %+v", run)
+	}
+
+	for _, term := range opts.Terms {
+		sections, err := db.ListSections(ctx, term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sections) != 6 || sections[0].CRN != "10084" || sections[0].ID != term+"-10084" {
+			t.Fatalf("%s: one term overwrote the other, got %+v", term, sections)
+		}
+	}
+
+	// and a second run finds both terms unchanged
+	run, err = Run(ctx, db.Store(), "test", opts)
+	if err != nil || run.Unchanged != 12 || run.Added+run.Changed+run.Removed != 0 {
+		t.Fatalf("second run: %+v, %v", run, err)
+	}
+}
+
+// a section stored before terms were can't be pinned to one when several are scraped
+func TestPruneKeepsLegacySectionsAcrossTerms(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(bannermock.New(bannermock.Sample(), bannermock.Options{}))
+	defer srv.Close()
+	t.Setenv("BANNER_BASE_URL", srv.URL+bannermock.Prefix)
+
+	db := memory.New()
+	legacy := types.Section{ID: "99999", CourseID: "CS110"}
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS110", Department: "CS"}, []types.Section{legacy}); err != nil {
+		t.Fatal(err)
+	}
+	exists := func() bool {
+		sections, err := db.ListSections(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.ContainsFunc(sections, func(s types.Section) bool { return s.ID == legacy.ID })
+	}
+
+	opts := Options{Terms: []string{"202670", "202610"}, Subjects: []string{"CS"}, Workers: 2, Rate: 1000}
+	if run, err := Run(ctx, db.Store(), "test", opts); err != nil || run.Removed != 0 {
+		t.Fatalf("two terms: %+v, %v", run, err)
+	}
+	if !exists() {
+		t.Fatal("legacy section pruned by a run over two terms")
+	}
+
+	opts.Terms = []string{"202610"}
+	if run, err := Run(ctx, db.Store(), "test", opts); err != nil || run.Removed != 1 {
+		t.Fatalf("one term: %+v, %v", run, err)
+	}
+	if exists() {
+		t.Fatal("legacy section kept by a run over one term")
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// collects everything stored for a user into one archive, the same on every backend
+// schedules are nested like their documents on firestore: users/{userID}/schedules/{id}/versions.
+// generated schedules aren't stored (POST /api/generate only returns them), so they can't be part of it
+func ExportUser(ctx context.Context, store Store, userID string) (*types.AccountExport, error) {
+	user, err := store.Users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := store.Schedules.ListSchedules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := store.Tokens.ListTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []types.ExportedDoc{}
+	for _, s := range schedules {
+		data, err := toMap(s)
+		if err != nil {
+			return nil, err
+		}
+
+		history, err := store.Schedules.ListScheduleVersions(ctx, userID, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		versions := []types.ExportedDoc{}
+		for _, v := range history {
+			vdata, err := toMap(v)
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, types.ExportedDoc{ID: strconv.Itoa(v.Version), Data: vdata})
+		}
+
+		docs = append(docs, types.ExportedDoc{
+			ID:          s.ID,
+			Data:        data,
+			Collections: map[string][]types.ExportedDoc{"versions": versions},
+		})
+	}
+
+	return &types.AccountExport{
+		ExportedAt:  time.Now(),
+		Profile:     *user,
+		APITokens:   tokens,
+		Collections: map[string][]types.ExportedDoc{"schedules": docs},
+	}, nil
+}
+
+// struct -> generic map using the json field names (same as the firestore ones)
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
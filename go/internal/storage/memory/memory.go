@@ -0,0 +1,776 @@
+package memory
+
+// in-memory implementation of every storage repository
+// for running the backend offline and for handler tests.
+// everything is lost when the process exits, seed it from a fixture with Load
+//
+// mirrors the firestore layout (courses, sections linked by section_ids,
+// users with nested schedules) so behavior matches what production does
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+type DB struct {
+	mu sync.RWMutex
+
+	courses    map[string]types.Course
+	sections   map[string]types.Section
+	users      map[string]types.User
+	schedules  map[string]map[string]types.Schedule          // userID -> scheduleID -> schedule
+	guests     map[string]map[string]types.Schedule          // guestID -> scheduleID -> schedule
+	versions   map[string]map[string][]types.ScheduleVersion // userID -> scheduleID -> history, oldest first
+	tokens     map[string]types.APIToken
+	scrapeRuns map[string]types.ScrapeRun
+	changes    map[string]map[string]types.SectionChange // runID -> "{sectionID}_{kind}" -> change
+	scrapeLock *types.ScrapeLock
+}
+
+// shape of the seed file
+// schedules are keyed by user ID
+type Fixture struct {
+	Courses   []types.Course              `json:"courses"`
+	Sections  []types.Section             `json:"sections"`
+	Users     []types.User                `json:"users"`
+	Schedules map[string][]types.Schedule `json:"schedules"`
+}
+
+// empty database
+func New() *DB {
+	return &DB{
+		courses:    make(map[string]types.Course),
+		sections:   make(map[string]types.Section),
+		users:      make(map[string]types.User),
+		schedules:  make(map[string]map[string]types.Schedule),
+		guests:     make(map[string]map[string]types.Schedule),
+		versions:   make(map[string]map[string][]types.ScheduleVersion),
+		tokens:     make(map[string]types.APIToken),
+		scrapeRuns: make(map[string]types.ScrapeRun),
+		changes:    make(map[string]map[string]types.SectionChange),
+	}
+}
+
+// database seeded from a JSON fixture file
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+
+	db := New()
+	db.Seed(f)
+
+	log.Printf("memory store seeded from %s: %d courses, %d sections, %d users",
+		path, len(f.Courses), len(f.Sections), len(f.Users))
+	return db, nil
+}
+
+// adds fixture data as-is, courses keep the section_ids they were given
+func (db *DB) Seed(f Fixture) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// fixtures may be older than the current schema, upgrade them like a reader would
+	for _, c := range f.Courses {
+		c = cloneCourse(c)
+		schema.UpgradeCourse(&c)
+		db.courses[c.ID] = c
+	}
+	for _, s := range f.Sections {
+		s = cloneSection(s)
+		schema.UpgradeSection(&s)
+		db.sections[s.ID] = s
+	}
+	for _, u := range f.Users {
+		db.users[u.ID] = u
+	}
+	for userID, list := range f.Schedules {
+		for _, s := range list {
+			s = cloneSchedule(s)
+			schema.UpgradeSchedule(&s)
+			db.userSchedules(userID)[s.ID] = s
+		}
+	}
+}
+
+// every repository backed by this database
+func (db *DB) Store() storage.Store {
+	return storage.Store{
+		Courses:    db,
+		Sections:   db,
+		Users:      db,
+		Schedules:  db,
+		Guests:     db,
+		Tokens:     db,
+		ScrapeRuns: db,
+		Schema:     db,
+	}
+}
+
+// --- courses ---
+
+func (db *DB) SaveCourse(ctx context.Context, course types.Course) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.courses[course.ID] = stampedCourse(course)
+	return nil
+}
+
+func (db *DB) ListCourses(ctx context.Context) ([]types.Course, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	courses := make([]types.Course, 0, len(db.courses))
+	for _, c := range db.courses {
+		courses = append(courses, cloneCourse(c))
+	}
+
+	// map order is random, keep results stable for tests
+	sort.Slice(courses, func(i, j int) bool { return courses[i].ID < courses[j].ID })
+	return courses, nil
+}
+
+func (db *DB) CountCourses(ctx context.Context) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return int64(len(db.courses)), nil
+}
+
+// --- sections ---
+
+func (db *DB) SaveSection(ctx context.Context, section types.Section) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.sections[section.ID] = stampedSection(section)
+
+	// same as firestore: linking to a course that doesn't exist yet fails softly
+	course, ok := db.courses[section.CourseID]
+	if !ok {
+		log.Printf("Warning: Failed to link section %s to course %s: course not found", section.ID, section.CourseID)
+		return nil
+	}
+
+	if !slices.Contains(course.SectionIDs, section.ID) {
+		course.SectionIDs = append(course.SectionIDs, section.ID)
+		db.courses[course.ID] = course
+	}
+	return nil
+}
+
+// course metadata is merged and existing links kept, same as firestore
+func (db *DB) SaveCourseSections(ctx context.Context, course types.Course, sections []types.Section) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	existing, ok := db.courses[course.ID]
+	course = stampedCourse(course)
+	if ok {
+		course.SectionIDs = existing.SectionIDs
+	} else {
+		course.SectionIDs = []string{}
+	}
+
+	for _, s := range sections {
+		db.sections[s.ID] = stampedSection(s)
+		if !slices.Contains(course.SectionIDs, s.ID) {
+			course.SectionIDs = append(course.SectionIDs, s.ID)
+		}
+	}
+	db.courses[course.ID] = course
+	return nil
+}
+
+func (db *DB) PruneSections(ctx context.Context, term string, subjects []string, seen map[string]bool, legacy bool) (storage.PruneSummary, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var summary storage.PruneSummary
+
+	inScope := make(map[string]bool)
+	for id, c := range db.courses {
+		if slices.Contains(subjects, c.Department) {
+			inScope[id] = true
+		}
+	}
+
+	// stale sections of the term, and what's left per course afterwards
+	remaining := make(map[string]int)
+	for id, s := range db.sections {
+		if !inScope[s.CourseID] {
+			continue
+		}
+		if (s.Term == term || legacy && s.Term == "") && !seen[id] {
+			delete(db.sections, id)
+			summary.DeletedSections = append(summary.DeletedSections, id)
+			continue
+		}
+		remaining[s.CourseID]++
+	}
+
+	for id := range inScope {
+		// keep links to sections that exist, or that banner still lists
+		course := db.courses[id]
+		kept := []string{}
+		for _, secID := range course.SectionIDs {
+			if _, ok := db.sections[secID]; ok || seen[secID] {
+				kept = append(kept, secID)
+			}
+		}
+
+		if remaining[id] == 0 && len(kept) == 0 {
+			delete(db.courses, id)
+			summary.DeletedCourses = append(summary.DeletedCourses, id)
+			continue
+		}
+
+		summary.RemovedLinks += len(course.SectionIDs) - len(kept)
+		course.SectionIDs = kept
+		db.courses[id] = course
+	}
+
+	sort.Strings(summary.DeletedSections)
+	sort.Strings(summary.DeletedCourses)
+	return summary, nil
+}
+
+func (db *DB) GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	course, ok := db.courses[courseID]
+	if !ok {
+		return nil, fmt.Errorf("failed to find course %s: %w", courseID, storage.ErrNotFound)
+	}
+
+	sections := []types.Section{}
+	for _, id := range course.SectionIDs {
+		// in case a section was deleted but ID remains
+		if s, ok := db.sections[id]; ok {
+			sections = append(sections, cloneSection(s))
+		}
+	}
+	return sections, nil
+}
+
+func (db *DB) ListSections(ctx context.Context, term string) ([]types.Section, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	sections := []types.Section{}
+	for _, s := range db.sections {
+		if term == "" || s.Term == term {
+			sections = append(sections, cloneSection(s))
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].ID < sections[j].ID })
+	return sections, nil
+}
+
+// a scan, the whole catalog is in memory anyway
+func (db *DB) ListSectionsByInstructor(ctx context.Context, instructorID, term string) ([]types.Section, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	sections := []types.Section{}
+	for _, s := range db.sections {
+		if term != "" && s.Term != term {
+			continue
+		}
+		if slices.Contains(types.InstructorKeys(s.Instructors), instructorID) {
+			sections = append(sections, cloneSection(s))
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].ID < sections[j].ID })
+	return sections, nil
+}
+
+func (db *DB) CountSections(ctx context.Context) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return int64(len(db.sections)), nil
+}
+
+// --- users ---
+
+func (db *DB) SaveUser(ctx context.Context, user types.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// merge like firestore.MergeAll, an empty role keeps the stored one
+	if existing, ok := db.users[user.ID]; ok && user.Role == "" {
+		user.Role = existing.Role
+	}
+	db.users[user.ID] = user
+	return nil
+}
+
+func (db *DB) GetUser(ctx context.Context, userID string) (*types.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (db *DB) ListUsers(ctx context.Context) ([]types.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	users := make([]types.User, 0, len(db.users))
+	for _, u := range db.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (db *DB) CountUsers(ctx context.Context) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return int64(len(db.users)), nil
+}
+
+func (db *DB) DeleteUser(ctx context.Context, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.users, userID)
+	delete(db.schedules, userID)
+	delete(db.versions, userID)
+	for id, t := range db.tokens {
+		if t.UserID == userID {
+			delete(db.tokens, id)
+		}
+	}
+	return nil
+}
+
+// --- schedules ---
+
+func (db *DB) SaveSchedule(ctx context.Context, userID string, schedule types.Schedule) (*types.Schedule, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if schedule.ID == "" {
+		schedule.ID = newID()
+	}
+
+	// a missing schedule counts as revision 0
+	current, exists := db.userSchedules(userID)[schedule.ID]
+	if exists && current.Revision != schedule.Revision {
+		current = cloneSchedule(current)
+		return nil, &storage.ConflictError{Current: &current}
+	}
+	if !exists && schedule.Revision != 0 {
+		return nil, &storage.ConflictError{}
+	}
+
+	if db.versions[userID] == nil {
+		db.versions[userID] = make(map[string][]types.ScheduleVersion)
+	}
+	history := db.versions[userID][schedule.ID]
+
+	next := 1
+	if len(history) > 0 {
+		next = history[len(history)-1].Version + 1
+	}
+
+	now := time.Now()
+	schedule.UserID = userID
+	schedule.Revision = next
+	schedule.UpdatedAt = now
+	stored := stampedSchedule(schedule)
+	db.userSchedules(userID)[schedule.ID] = stored
+
+	history = append(history, types.ScheduleVersion{Version: next, SavedAt: now, Schedule: cloneSchedule(stored)})
+	if len(history) > storage.ScheduleHistoryLimit {
+		history = history[len(history)-storage.ScheduleHistoryLimit:]
+	}
+	db.versions[userID][schedule.ID] = history
+
+	saved := cloneSchedule(stored)
+	return &saved, nil
+}
+
+func (db *DB) GetSchedule(ctx context.Context, userID, scheduleID string) (*types.Schedule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	s, ok := db.schedules[userID][scheduleID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	s = cloneSchedule(s)
+	return &s, nil
+}
+
+func (db *DB) ListScheduleVersions(ctx context.Context, userID, scheduleID string) ([]types.ScheduleVersion, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	history := db.versions[userID][scheduleID]
+	versions := make([]types.ScheduleVersion, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		v := history[i]
+		v.Schedule = cloneSchedule(v.Schedule)
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (db *DB) GetScheduleVersion(ctx context.Context, userID, scheduleID string, version int) (*types.ScheduleVersion, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, v := range db.versions[userID][scheduleID] {
+		if v.Version == version {
+			v.Schedule = cloneSchedule(v.Schedule)
+			return &v, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (db *DB) ListSchedules(ctx context.Context, userID string) ([]types.Schedule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return sortedSchedules(db.schedules[userID]), nil
+}
+
+// --- guests ---
+
+func (db *DB) SaveGuestSchedule(ctx context.Context, guestID string, schedule types.Schedule) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if schedule.ID == "" {
+		schedule.ID = newID()
+	}
+	// guests have no user ID yet, it gets filled in on merge
+	schedule.UserID = ""
+
+	if db.guests[guestID] == nil {
+		db.guests[guestID] = make(map[string]types.Schedule)
+	}
+	db.guests[guestID][schedule.ID] = stampedSchedule(schedule)
+	return schedule.ID, nil
+}
+
+func (db *DB) ListGuestSchedules(ctx context.Context, guestID string) ([]types.Schedule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return sortedSchedules(db.guests[guestID]), nil
+}
+
+func (db *DB) DeleteGuestSchedule(ctx context.Context, guestID, scheduleID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.guests[guestID], scheduleID)
+	return nil
+}
+
+func (db *DB) DeleteGuest(ctx context.Context, guestID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.guests, guestID)
+	return nil
+}
+
+// --- api tokens ---
+
+func (db *DB) SaveToken(ctx context.Context, token types.APIToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.tokens[token.ID] = token
+	return nil
+}
+
+func (db *DB) GetToken(ctx context.Context, tokenID string) (*types.APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	token, ok := db.tokens[tokenID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &token, nil
+}
+
+func (db *DB) ListTokens(ctx context.Context, userID string) ([]types.APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tokens := []types.APIToken{}
+	for _, t := range db.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (db *DB) DeleteToken(ctx context.Context, userID, tokenID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// don't leak whether someone else's token exists
+	token, ok := db.tokens[tokenID]
+	if !ok || token.UserID != userID {
+		return storage.ErrNotFound
+	}
+	delete(db.tokens, tokenID)
+	return nil
+}
+
+func (db *DB) TouchToken(ctx context.Context, tokenID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	token, ok := db.tokens[tokenID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	token.LastUsedAt = time.Now()
+	db.tokens[tokenID] = token
+	return nil
+}
+
+// --- scrape runs ---
+
+func (db *DB) SaveScrapeRun(ctx context.Context, run types.ScrapeRun) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	run.Terms = append([]string(nil), run.Terms...)
+	run.Subjects = append([]string(nil), run.Subjects...)
+	run.Errors = append([]string(nil), run.Errors...)
+	db.scrapeRuns[run.ID] = run
+	return nil
+}
+
+func (db *DB) ListScrapeRuns(ctx context.Context, limit int) ([]types.ScrapeRun, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	runs := make([]types.ScrapeRun, 0, len(db.scrapeRuns))
+	for _, r := range db.scrapeRuns {
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (db *DB) GetScrapeRun(ctx context.Context, runID string) (*types.ScrapeRun, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	run, ok := db.scrapeRuns[runID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &run, nil
+}
+
+func (db *DB) SaveScrapeChanges(ctx context.Context, runID string, changes []types.SectionChange) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.changes[runID] == nil {
+		db.changes[runID] = make(map[string]types.SectionChange)
+	}
+	for _, c := range changes {
+		db.changes[runID][c.SectionID+"_"+c.Kind] = c
+	}
+	return nil
+}
+
+func (db *DB) ListScrapeChanges(ctx context.Context, runID string) ([]types.SectionChange, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	changes := make([]types.SectionChange, 0, len(db.changes[runID]))
+	for _, c := range db.changes[runID] {
+		changes = append(changes, c)
+	}
+	storage.SortChanges(changes)
+	return changes, nil
+}
+
+func (db *DB) AcquireScrapeLock(ctx context.Context, lock types.ScrapeLock) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if cur := db.scrapeLock; cur != nil && cur.Holder != lock.Holder && time.Now().Before(cur.ExpiresAt) {
+		return &storage.LockedError{Current: *cur}
+	}
+	db.scrapeLock = &lock
+	return nil
+}
+
+func (db *DB) ReleaseScrapeLock(ctx context.Context, holder string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.scrapeLock != nil && db.scrapeLock.Holder == holder {
+		db.scrapeLock = nil
+	}
+	return nil
+}
+
+// --- schema ---
+
+// writes are stamped and fixtures upgraded on load, so this mostly finds nothing.
+// kept so cmd/migrate works against every backend
+func (db *DB) MigrateDocuments(ctx context.Context, dryRun bool) ([]storage.MigrationResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	courses := storage.MigrationResult{Collection: "courses", Failed: []string{}}
+	for id, c := range db.courses {
+		courses.Scanned++
+		if schema.UpgradeCourse(&c) {
+			courses.Outdated++
+			if !dryRun {
+				db.courses[id] = c
+				courses.Upgraded++
+			}
+		}
+	}
+
+	sections := storage.MigrationResult{Collection: "sections", Failed: []string{}}
+	for id, s := range db.sections {
+		sections.Scanned++
+		if schema.UpgradeSection(&s) {
+			sections.Outdated++
+			if !dryRun {
+				db.sections[id] = s
+				sections.Upgraded++
+			}
+		}
+	}
+
+	schedules := storage.MigrationResult{Collection: "schedules", Failed: []string{}}
+	for _, owners := range []map[string]map[string]types.Schedule{db.schedules, db.guests} {
+		for _, list := range owners {
+			for id, s := range list {
+				schedules.Scanned++
+				// clone first, the upgrade may touch embedded sections
+				s = cloneSchedule(s)
+				if schema.UpgradeSchedule(&s) {
+					schedules.Outdated++
+					if !dryRun {
+						list[id] = s
+						schedules.Upgraded++
+					}
+				}
+			}
+		}
+	}
+
+	return []storage.MigrationResult{courses, sections, schedules}, nil
+}
+
+// --- helpers ---
+
+// caller must hold the write lock
+func (db *DB) userSchedules(userID string) map[string]types.Schedule {
+	if db.schedules[userID] == nil {
+		db.schedules[userID] = make(map[string]types.Schedule)
+	}
+	return db.schedules[userID]
+}
+
+func sortedSchedules(m map[string]types.Schedule) []types.Schedule {
+	schedules := make([]types.Schedule, 0, len(m))
+	for _, s := range m {
+		schedules = append(schedules, cloneSchedule(s))
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+// copies so callers can't mutate stored slices behind our back
+
+func cloneCourse(c types.Course) types.Course {
+	c.SectionIDs = append([]string(nil), c.SectionIDs...)
+	return c
+}
+
+func cloneSection(s types.Section) types.Section {
+	s.Meetings = append([]types.Meeting(nil), s.Meetings...)
+	s.Instructors = append([]types.Instructor(nil), s.Instructors...)
+	return s
+}
+
+func cloneSchedule(s types.Schedule) types.Schedule {
+	sections := make([]types.Section, len(s.Sections))
+	for i, sec := range s.Sections {
+		sections[i] = cloneSection(sec)
+	}
+	s.Sections = sections
+	return s
+}
+
+// copies stamped with the current schema version, for writes
+
+func stampedCourse(c types.Course) types.Course {
+	c = cloneCourse(c)
+	schema.StampCourse(&c)
+	return c
+}
+
+func stampedSection(s types.Section) types.Section {
+	s = cloneSection(s)
+	schema.StampSection(&s)
+	return s
+}
+
+func stampedSchedule(s types.Schedule) types.Schedule {
+	s = cloneSchedule(s)
+	schema.StampSchedule(&s)
+	return s
+}
+
+// 20 hex chars, close enough to a firestore auto ID
+func newID() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
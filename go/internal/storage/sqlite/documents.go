@@ -0,0 +1,183 @@
+package sqlite
+
+// document level schema upgrades (internal/schema), separate from the SQL migrations in migrate.go.
+// SQL migrations change tables, these rewrite rows whose JSON columns or
+// schema_version are behind what this binary writes
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// everything runs in one transaction, a failed run leaves the database untouched
+func (db *DB) MigrateDocuments(ctx context.Context, dryRun bool) ([]storage.MigrationResult, error) {
+	var results []storage.MigrationResult
+
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		courses, err := migrateCourses(ctx, tx, dryRun)
+		if err != nil {
+			return err
+		}
+
+		sections, err := migrateSections(ctx, tx, dryRun)
+		if err != nil {
+			return err
+		}
+
+		// user and guest schedules share a shape, report them together like firestore does
+		schedules, err := migrateSchedules(ctx, tx, "schedules", "user_id", dryRun)
+		if err != nil {
+			return err
+		}
+		guests, err := migrateSchedules(ctx, tx, "guest_schedules", "guest_id", dryRun)
+		if err != nil {
+			return err
+		}
+		schedules.Scanned += guests.Scanned
+		schedules.Outdated += guests.Outdated
+		schedules.Upgraded += guests.Upgraded
+		schedules.Failed = append(schedules.Failed, guests.Failed...)
+
+		results = []storage.MigrationResult{courses, sections, schedules}
+		return nil
+	})
+	return results, err
+}
+
+// section_ids live in course_sections, so only the version needs bumping
+func migrateCourses(ctx context.Context, tx *sql.Tx, dryRun bool) (storage.MigrationResult, error) {
+	result := storage.MigrationResult{Collection: "courses", Failed: []string{}}
+	current := schema.CourseVersion()
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(schema_version < ?), 0) FROM courses`, current).
+		Scan(&result.Scanned, &result.Outdated)
+	if err != nil || dryRun {
+		return result, err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE courses SET schema_version = ? WHERE schema_version < ?", current, current)
+	if err != nil {
+		return result, err
+	}
+	n, err := res.RowsAffected()
+	result.Upgraded = int(n)
+	return result, err
+}
+
+func migrateSections(ctx context.Context, tx *sql.Tx, dryRun bool) (storage.MigrationResult, error) {
+	result := storage.MigrationResult{Collection: "sections", Failed: []string{}}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sections").Scan(&result.Scanned); err != nil {
+		return result, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, course_id, section, professor, term, crn, meetings, instructors, hash, schema_version
+		FROM sections WHERE schema_version < ?`, schema.SectionVersion())
+	if err != nil {
+		return result, err
+	}
+
+	var outdated []types.Section
+	for rows.Next() {
+		var s types.Section
+		var meetings, instructors string
+		if err := rows.Scan(&s.ID, &s.CourseID, &s.Section, &s.Professor, &s.Term, &s.CRN, &meetings, &instructors, &s.Hash, &s.SchemaVersion); err != nil {
+			rows.Close()
+			return result, err
+		}
+		if err := fromJSON(meetings, &s.Meetings); err != nil {
+			result.Failed = append(result.Failed, s.ID)
+			continue
+		}
+		if err := fromJSON(instructors, &s.Instructors); err != nil {
+			result.Failed = append(result.Failed, s.ID)
+			continue
+		}
+		outdated = append(outdated, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	result.Outdated = len(outdated)
+	if dryRun {
+		return result, nil
+	}
+
+	// upsertSection stamps the current version
+	for _, s := range outdated {
+		if err := upsertSection(ctx, tx, s); err != nil {
+			return result, err
+		}
+		result.Upgraded++
+	}
+	return result, nil
+}
+
+func migrateSchedules(ctx context.Context, tx *sql.Tx, table, ownerColumn string, dryRun bool) (storage.MigrationResult, error) {
+	result := storage.MigrationResult{Collection: "schedules", Failed: []string{}}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&result.Scanned); err != nil {
+		return result, err
+	}
+
+	type row struct {
+		owner    string
+		schedule types.Schedule
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+ownerColumn+", id, name, sections, schema_version FROM "+table+" WHERE schema_version < ?",
+		schema.ScheduleVersion())
+	if err != nil {
+		return result, err
+	}
+
+	var outdated []row
+	for rows.Next() {
+		var r row
+		var sections string
+		if err := rows.Scan(&r.owner, &r.schedule.ID, &r.schedule.Name, &sections, &r.schedule.SchemaVersion); err != nil {
+			rows.Close()
+			return result, err
+		}
+		if err := fromJSON(sections, &r.schedule.Sections); err != nil {
+			result.Failed = append(result.Failed, r.schedule.ID)
+			continue
+		}
+		outdated = append(outdated, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	result.Outdated = len(outdated)
+	if dryRun {
+		return result, nil
+	}
+
+	for _, r := range outdated {
+		schema.StampSchedule(&r.schedule)
+		sections, err := toJSON(r.schedule.Sections)
+		if err != nil {
+			return result, err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE "+table+" SET sections = ?, schema_version = ? WHERE "+ownerColumn+" = ? AND id = ?",
+			sections, r.schedule.SchemaVersion, r.owner, r.schedule.ID)
+		if err != nil {
+			return result, err
+		}
+		result.Upgraded++
+	}
+	return result, nil
+}
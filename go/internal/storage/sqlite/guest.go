@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// saves a guest schedule, generating an ID if it has none
+// guests have no user ID yet, it gets filled in on merge
+func (db *DB) SaveGuestSchedule(ctx context.Context, guestID string, schedule types.Schedule) (string, error) {
+	if schedule.ID == "" {
+		schedule.ID = newID()
+	}
+	schedule.UserID = ""
+	schema.StampSchedule(&schedule)
+
+	sections, err := toJSON(schedule.Sections)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO guest_schedules (guest_id, id, name, sections, schema_version, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		guestID, schedule.ID, schedule.Name, sections, schedule.SchemaVersion, formatTime(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return schedule.ID, nil
+}
+
+func (db *DB) ListGuestSchedules(ctx context.Context, guestID string) ([]types.Schedule, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT id, name, sections, schema_version, 0, updated_at FROM guest_schedules WHERE guest_id = ? ORDER BY id", guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSchedules(rows, "")
+}
+
+func (db *DB) DeleteGuestSchedule(ctx context.Context, guestID, scheduleID string) error {
+	_, err := db.db.ExecContext(ctx, "DELETE FROM guest_schedules WHERE guest_id = ? AND id = ?", guestID, scheduleID)
+	return err
+}
+
+func (db *DB) DeleteGuest(ctx context.Context, guestID string) error {
+	_, err := db.db.ExecContext(ctx, "DELETE FROM guest_schedules WHERE guest_id = ?", guestID)
+	return err
+}
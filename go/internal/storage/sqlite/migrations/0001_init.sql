@@ -0,0 +1,83 @@
+-- initial schema, mirrors the firestore collections
+-- nested lists (meetings, schedule sections, ...) are stored as JSON text
+-- timestamps are fixed width UTC text so they sort correctly
+
+CREATE TABLE courses (
+    id          TEXT PRIMARY KEY,
+    department  TEXT NOT NULL DEFAULT '',
+    code        TEXT NOT NULL DEFAULT '',
+    title       TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    credits     INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX courses_department ON courses (department);
+
+CREATE TABLE sections (
+    id        TEXT PRIMARY KEY, -- CRN
+    course_id TEXT NOT NULL,
+    section   TEXT NOT NULL DEFAULT '',
+    professor TEXT NOT NULL DEFAULT '',
+    meetings  TEXT NOT NULL DEFAULT '[]'
+);
+
+-- the course -> section index, same role as courses.section_ids in firestore
+-- no foreign key on section_id: like firestore, a link can outlive its section
+CREATE TABLE course_sections (
+    course_id  TEXT NOT NULL REFERENCES courses (id) ON DELETE CASCADE,
+    section_id TEXT NOT NULL,
+    position   INTEGER NOT NULL,
+    PRIMARY KEY (course_id, section_id)
+);
+
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL DEFAULT '',
+    email      TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    role       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE schedules (
+    user_id  TEXT NOT NULL,
+    id       TEXT NOT NULL,
+    name     TEXT NOT NULL DEFAULT '',
+    sections TEXT NOT NULL DEFAULT '[]',
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE guest_schedules (
+    guest_id   TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    name       TEXT NOT NULL DEFAULT '',
+    sections   TEXT NOT NULL DEFAULT '[]',
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (guest_id, id)
+);
+
+CREATE TABLE api_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    hash         TEXT NOT NULL,
+    read_only    INTEGER NOT NULL DEFAULT 0,
+    created_at   TEXT NOT NULL,
+    last_used_at TEXT NOT NULL
+);
+
+CREATE INDEX api_tokens_user_id ON api_tokens (user_id);
+
+CREATE TABLE scrape_runs (
+    id          TEXT PRIMARY KEY,
+    trigger_by  TEXT NOT NULL DEFAULT '',
+    term        TEXT NOT NULL DEFAULT '',
+    subjects    TEXT NOT NULL DEFAULT '[]',
+    status      TEXT NOT NULL DEFAULT '',
+    started_at  TEXT NOT NULL,
+    finished_at TEXT NOT NULL,
+    courses     INTEGER NOT NULL DEFAULT 0,
+    sections    INTEGER NOT NULL DEFAULT 0,
+    errors      TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX scrape_runs_started_at ON scrape_runs (started_at);
@@ -0,0 +1,6 @@
+-- banner term a section belongs to, used to scope pruning after a scrape
+-- existing rows get '' which prune treats as the scraped term
+
+ALTER TABLE sections ADD COLUMN term TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX sections_course_id ON sections (course_id);
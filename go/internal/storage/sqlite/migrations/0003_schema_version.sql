@@ -0,0 +1,7 @@
+-- document schema version, see internal/schema
+-- existing rows get 0 and are upgraded on read or by cmd/migrate
+
+ALTER TABLE courses ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sections ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE schedules ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE guest_schedules ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 0;
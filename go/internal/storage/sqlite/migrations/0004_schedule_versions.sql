@@ -0,0 +1,13 @@
+-- every save of a user schedule, bounded per schedule by storage.ScheduleHistoryLimit
+-- same role as users/{userID}/schedules/{scheduleID}/versions in firestore
+
+CREATE TABLE schedule_versions (
+    user_id        TEXT NOT NULL,
+    schedule_id    TEXT NOT NULL,
+    version        INTEGER NOT NULL,
+    saved_at       TEXT NOT NULL,
+    name           TEXT NOT NULL DEFAULT '',
+    sections       TEXT NOT NULL DEFAULT '[]',
+    schema_version INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, schedule_id, version)
+);
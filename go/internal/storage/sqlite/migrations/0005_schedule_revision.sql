@@ -0,0 +1,5 @@
+-- optimistic concurrency for user schedules, see storage.ScheduleRepository
+-- existing rows start at revision 0, which is what clients that loaded them will send back
+
+ALTER TABLE schedules ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE schedules ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
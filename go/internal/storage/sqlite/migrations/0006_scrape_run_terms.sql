@@ -0,0 +1,5 @@
+-- a scrape run can cover several terms now, stored as a JSON list like subjects
+
+ALTER TABLE scrape_runs ADD COLUMN terms TEXT NOT NULL DEFAULT '[]';
+UPDATE scrape_runs SET terms = json_array(term) WHERE term != '';
+ALTER TABLE scrape_runs DROP COLUMN term;
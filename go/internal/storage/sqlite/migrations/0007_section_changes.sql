@@ -0,0 +1,22 @@
+-- incremental scraping: sections remember the hash they were written with,
+-- and every run keeps a log of what it added, removed or changed
+-- same role as sections.hash and scrape_runs/{runID}/changes in firestore
+
+ALTER TABLE sections ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE scrape_runs ADD COLUMN added INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_runs ADD COLUMN changed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_runs ADD COLUMN removed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_runs ADD COLUMN unchanged INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE scrape_changes (
+    run_id     TEXT NOT NULL,
+    section_id TEXT NOT NULL,
+    course_id  TEXT NOT NULL DEFAULT '',
+    section    TEXT NOT NULL DEFAULT '',
+    term       TEXT NOT NULL DEFAULT '',
+    kind       TEXT NOT NULL,
+    before     TEXT NOT NULL DEFAULT '',
+    after      TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (run_id, section_id, kind)
+);
@@ -0,0 +1,10 @@
+-- lease that keeps scrapes in different processes from overlapping
+-- one row per lock, only 'scraper' for now. same role as scrape_locks/{name} in firestore
+
+CREATE TABLE scrape_locks (
+    name        TEXT PRIMARY KEY,
+    holder      TEXT NOT NULL,
+    run_id      TEXT NOT NULL DEFAULT '',
+    acquired_at TEXT NOT NULL,
+    expires_at  TEXT NOT NULL
+);
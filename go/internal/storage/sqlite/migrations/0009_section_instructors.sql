@@ -0,0 +1,17 @@
+-- every instructor of a section, not just the professor's name
+-- instructors is the list as JSON, section_instructors indexes it by instructor
+-- same role as sections.instructors and sections.instructor_ids in firestore
+--
+-- existing rows get their list from professor when read (schema version 2),
+-- the index fills in as sections are rescraped or rewritten by cmd/migrate
+
+ALTER TABLE sections ADD COLUMN instructors TEXT NOT NULL DEFAULT '[]';
+
+-- no foreign key, rows are replaced with their section in upsertSection and removed with it in prune
+CREATE TABLE section_instructors (
+    instructor_id TEXT NOT NULL,
+    section_id    TEXT NOT NULL,
+    PRIMARY KEY (instructor_id, section_id)
+);
+
+CREATE INDEX section_instructors_section ON section_instructors (section_id);
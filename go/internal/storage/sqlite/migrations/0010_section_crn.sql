@@ -0,0 +1,4 @@
+-- sections are keyed by term and CRN now, banner reuses CRNs across terms
+-- existing rows are keyed by the bare CRN and get it filled in when read (schema version 3)
+
+ALTER TABLE sections ADD COLUMN crn TEXT NOT NULL DEFAULT '';
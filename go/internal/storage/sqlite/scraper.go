@@ -0,0 +1,550 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// saves the course metadata, overwriting it if it exists
+// section_ids are replaced too, same as a firestore Set
+func (db *DB) SaveCourse(ctx context.Context, course types.Course) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if err := upsertCourse(ctx, tx, course); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM course_sections WHERE course_id = ?", course.ID); err != nil {
+			return err
+		}
+		for i, id := range course.SectionIDs {
+			_, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO course_sections (course_id, section_id, position) VALUES (?, ?, ?)",
+				course.ID, id, i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) ListCourses(ctx context.Context) ([]types.Course, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT id, department, code, title, description, credits, schema_version FROM courses ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := []types.Course{}
+	byID := make(map[string]int)
+	for rows.Next() {
+		var c types.Course
+		if err := rows.Scan(&c.ID, &c.Department, &c.Code, &c.Title, &c.Description, &c.Credits, &c.SchemaVersion); err != nil {
+			return nil, err
+		}
+		// links live in course_sections, so section_ids is never null here
+		c.SectionIDs = []string{}
+		schema.UpgradeCourse(&c)
+		byID[c.ID] = len(courses)
+		courses = append(courses, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// fill in section_ids with one extra query instead of one per course
+	links, err := db.db.QueryContext(ctx,
+		"SELECT course_id, section_id FROM course_sections ORDER BY course_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer links.Close()
+
+	for links.Next() {
+		var courseID, sectionID string
+		if err := links.Scan(&courseID, &sectionID); err != nil {
+			return nil, err
+		}
+		if i, ok := byID[courseID]; ok {
+			courses[i].SectionIDs = append(courses[i].SectionIDs, sectionID)
+		}
+	}
+	return courses, links.Err()
+}
+
+func (db *DB) CountCourses(ctx context.Context) (int64, error) {
+	return db.count(ctx, "courses")
+}
+
+// saves the section and links it to its course
+// like firestore, the link is skipped with a warning if the course doesn't exist yet
+func (db *DB) SaveSection(ctx context.Context, section types.Section) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if err := upsertSection(ctx, tx, section); err != nil {
+			return err
+		}
+
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM courses WHERE id = ?)", section.CourseID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			log.Printf("Warning: Failed to link section %s to course %s: course not found", section.ID, section.CourseID)
+			return nil
+		}
+
+		return linkSection(ctx, tx, section.CourseID, section.ID)
+	})
+}
+
+// course upsert, section upserts and links all go in one transaction
+// existing links are kept, same as the firestore merge
+func (db *DB) SaveCourseSections(ctx context.Context, course types.Course, sections []types.Section) error {
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		if err := upsertCourse(ctx, tx, course); err != nil {
+			return err
+		}
+		for _, s := range sections {
+			if err := upsertSection(ctx, tx, s); err != nil {
+				return err
+			}
+			if err := linkSection(ctx, tx, course.ID, s.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	// rolled back, so nothing in the batch was written
+	failures := []storage.WriteFailure{{Collection: "courses", ID: course.ID, Err: err}}
+	for _, s := range sections {
+		failures = append(failures, storage.WriteFailure{Collection: "sections", ID: s.ID, Err: err})
+	}
+	return &storage.BatchError{Failures: failures}
+}
+
+// updates metadata only, links are handled separately
+func upsertCourse(ctx context.Context, tx *sql.Tx, course types.Course) error {
+	schema.StampCourse(&course)
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO courses (id, department, code, title, description, credits, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			department = excluded.department,
+			code = excluded.code,
+			title = excluded.title,
+			description = excluded.description,
+			credits = excluded.credits,
+			schema_version = excluded.schema_version`,
+		course.ID, course.Department, course.Code, course.Title, course.Description, course.Credits, course.SchemaVersion)
+	return err
+}
+
+func upsertSection(ctx context.Context, tx *sql.Tx, section types.Section) error {
+	schema.StampSection(&section)
+
+	meetings, err := toJSON(section.Meetings)
+	if err != nil {
+		return err
+	}
+
+	instructors, err := toJSON(section.Instructors)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sections (id, course_id, section, professor, term, crn, meetings, instructors, hash, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			course_id = excluded.course_id,
+			section = excluded.section,
+			professor = excluded.professor,
+			term = excluded.term,
+			crn = excluded.crn,
+			meetings = excluded.meetings,
+			instructors = excluded.instructors,
+			hash = excluded.hash,
+			schema_version = excluded.schema_version`,
+		section.ID, section.CourseID, section.Section, section.Professor, section.Term, section.CRN, meetings, instructors, section.Hash, section.SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	// the instructor index is replaced with the section
+	if _, err := tx.ExecContext(ctx, "DELETE FROM section_instructors WHERE section_id = ?", section.ID); err != nil {
+		return err
+	}
+	for _, key := range types.InstructorKeys(section.Instructors) {
+		_, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO section_instructors (instructor_id, section_id) VALUES (?, ?)", key, section.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appends to the end of the course's list, no-op if already linked
+func linkSection(ctx context.Context, tx *sql.Tx, courseID, sectionID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO course_sections (course_id, section_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), -1) + 1 FROM course_sections WHERE course_id = ?`,
+		courseID, sectionID, courseID)
+	return err
+}
+
+func (db *DB) PruneSections(ctx context.Context, term string, subjects []string, seen map[string]bool, legacy bool) (storage.PruneSummary, error) {
+	var summary storage.PruneSummary
+	if len(subjects) == 0 {
+		return summary, nil
+	}
+
+	args := make([]interface{}, len(subjects))
+	for i, subj := range subjects {
+		args[i] = subj
+	}
+	scope := "SELECT id FROM courses WHERE department IN (" + placeholders(len(subjects)) + ")"
+
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		// stale sections of the term
+		terms := []interface{}{term}
+		if legacy {
+			terms = append(terms, "")
+		}
+		stale, err := queryIDs(ctx, tx,
+			"SELECT id FROM sections WHERE course_id IN ("+scope+") AND term IN ("+placeholders(len(terms))+") ORDER BY id",
+			append(args, terms...)...)
+		if err != nil {
+			return err
+		}
+		for _, id := range stale {
+			if seen[id] {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM sections WHERE id = ?", id); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM section_instructors WHERE section_id = ?", id); err != nil {
+				return err
+			}
+			summary.DeletedSections = append(summary.DeletedSections, id)
+		}
+
+		// links to sections that are gone, unless banner still lists them
+		rows, err := tx.QueryContext(ctx, `
+			SELECT cs.course_id, cs.section_id
+			FROM course_sections cs
+			LEFT JOIN sections s ON s.id = cs.section_id
+			WHERE s.id IS NULL AND cs.course_id IN (`+scope+`)`, args...)
+		if err != nil {
+			return err
+		}
+		var dangling [][2]string
+		for rows.Next() {
+			var link [2]string
+			if err := rows.Scan(&link[0], &link[1]); err != nil {
+				rows.Close()
+				return err
+			}
+			if !seen[link[1]] {
+				dangling = append(dangling, link)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, link := range dangling {
+			_, err := tx.ExecContext(ctx,
+				"DELETE FROM course_sections WHERE course_id = ? AND section_id = ?", link[0], link[1])
+			if err != nil {
+				return err
+			}
+		}
+
+		// courses with nothing left, their links go with them (ON DELETE CASCADE)
+		empty, err := queryIDs(ctx, tx, `
+			SELECT c.id FROM courses c
+			WHERE c.id IN (`+scope+`)
+			AND NOT EXISTS (SELECT 1 FROM sections s WHERE s.course_id = c.id)
+			AND NOT EXISTS (SELECT 1 FROM course_sections cs WHERE cs.course_id = c.id)
+			ORDER BY c.id`, args...)
+		if err != nil {
+			return err
+		}
+		for _, id := range empty {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM courses WHERE id = ?", id); err != nil {
+				return err
+			}
+		}
+		summary.DeletedCourses = empty
+
+		// only count links on courses that were kept, like the other backends
+		for _, link := range dangling {
+			if !slices.Contains(empty, link[0]) {
+				summary.RemovedLinks++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.PruneSummary{}, err
+	}
+	return summary, nil
+}
+
+// sections linked to the course, in link order
+// links whose section no longer exists are skipped
+func (db *DB) GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section, error) {
+	var exists bool
+	err := db.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM courses WHERE id = ?)", courseID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("failed to find course %s: %w", courseID, storage.ErrNotFound)
+	}
+
+	rows, err := db.db.QueryContext(ctx, `
+		SELECT s.id, s.course_id, s.section, s.professor, s.term, s.crn, s.meetings, s.instructors, s.hash, s.schema_version
+		FROM course_sections cs
+		JOIN sections s ON s.id = cs.section_id
+		WHERE cs.course_id = ?
+		ORDER BY cs.position`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSections(rows)
+}
+
+func (db *DB) ListSections(ctx context.Context, term string) ([]types.Section, error) {
+	rows, err := db.db.QueryContext(ctx, `
+		SELECT id, course_id, section, professor, term, crn, meetings, instructors, hash, schema_version
+		FROM sections
+		WHERE ? = '' OR term = ?
+		ORDER BY id`, term, term)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSections(rows)
+}
+
+func (db *DB) ListSectionsByInstructor(ctx context.Context, instructorID, term string) ([]types.Section, error) {
+	rows, err := db.db.QueryContext(ctx, `
+		SELECT s.id, s.course_id, s.section, s.professor, s.term, s.crn, s.meetings, s.instructors, s.hash, s.schema_version
+		FROM section_instructors si
+		JOIN sections s ON s.id = si.section_id
+		WHERE si.instructor_id = ? AND (? = '' OR s.term = ?)
+		ORDER BY s.id`, instructorID, term, term)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSections(rows)
+}
+
+// rows of (id, course_id, section, professor, term, crn, meetings, instructors, hash, schema_version)
+func scanSections(rows *sql.Rows) ([]types.Section, error) {
+	sections := []types.Section{}
+	for rows.Next() {
+		var s types.Section
+		var meetings, instructors string
+		if err := rows.Scan(&s.ID, &s.CourseID, &s.Section, &s.Professor, &s.Term, &s.CRN, &meetings, &instructors, &s.Hash, &s.SchemaVersion); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(meetings, &s.Meetings); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(instructors, &s.Instructors); err != nil {
+			return nil, err
+		}
+		schema.UpgradeSection(&s)
+		sections = append(sections, s)
+	}
+	return sections, rows.Err()
+}
+
+func (db *DB) CountSections(ctx context.Context) (int64, error) {
+	return db.count(ctx, "sections")
+}
+
+func (db *DB) SaveScrapeRun(ctx context.Context, run types.ScrapeRun) error {
+	terms, err := toJSON(run.Terms)
+	if err != nil {
+		return err
+	}
+	subjects, err := toJSON(run.Subjects)
+	if err != nil {
+		return err
+	}
+	runErrors, err := toJSON(run.Errors)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO scrape_runs
+			(id, trigger_by, terms, subjects, status, started_at, finished_at,
+			 courses, sections, added, changed, removed, unchanged, errors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Trigger, terms, subjects, run.Status,
+		formatTime(run.StartedAt), formatTime(run.FinishedAt), run.Courses, run.Sections,
+		run.Added, run.Changed, run.Removed, run.Unchanged, runErrors)
+	return err
+}
+
+const scrapeRunColumns = "id, trigger_by, terms, subjects, status, started_at, finished_at, " +
+	"courses, sections, added, changed, removed, unchanged, errors"
+
+func (db *DB) ListScrapeRuns(ctx context.Context, limit int) ([]types.ScrapeRun, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT "+scrapeRunColumns+" FROM scrape_runs ORDER BY started_at DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []types.ScrapeRun{}
+	for rows.Next() {
+		run, err := scanScrapeRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+func (db *DB) GetScrapeRun(ctx context.Context, runID string) (*types.ScrapeRun, error) {
+	row := db.db.QueryRowContext(ctx, "SELECT "+scrapeRunColumns+" FROM scrape_runs WHERE id = ?", runID)
+
+	run, err := scanScrapeRun(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	return run, err
+}
+
+// works for both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScrapeRun(row scanner) (*types.ScrapeRun, error) {
+	var run types.ScrapeRun
+	var terms, subjects, runErrors, startedAt, finishedAt string
+
+	err := row.Scan(&run.ID, &run.Trigger, &terms, &subjects, &run.Status,
+		&startedAt, &finishedAt, &run.Courses, &run.Sections,
+		&run.Added, &run.Changed, &run.Removed, &run.Unchanged, &runErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	run.StartedAt = parseTime(startedAt)
+	run.FinishedAt = parseTime(finishedAt)
+	if err := fromJSON(terms, &run.Terms); err != nil {
+		return nil, err
+	}
+	if err := fromJSON(subjects, &run.Subjects); err != nil {
+		return nil, err
+	}
+	if err := fromJSON(runErrors, &run.Errors); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (db *DB) SaveScrapeChanges(ctx context.Context, runID string, changes []types.SectionChange) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		for _, c := range changes {
+			_, err := tx.ExecContext(ctx, `
+				INSERT OR REPLACE INTO scrape_changes (run_id, section_id, course_id, section, term, kind, before, after)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				runID, c.SectionID, c.CourseID, c.Section, c.Term, c.Kind, c.Before, c.After)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) ListScrapeChanges(ctx context.Context, runID string) ([]types.SectionChange, error) {
+	rows, err := db.db.QueryContext(ctx, `
+		SELECT section_id, course_id, section, term, kind, before, after
+		FROM scrape_changes
+		WHERE run_id = ?
+		ORDER BY course_id, section_id, kind`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []types.SectionChange{}
+	for rows.Next() {
+		var c types.SectionChange
+		if err := rows.Scan(&c.SectionID, &c.CourseID, &c.Section, &c.Term, &c.Kind, &c.Before, &c.After); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// one conditional upsert, so the check and the write can't be split by another process
+// timestamps are fixed width UTC, string comparison is time comparison
+func (db *DB) AcquireScrapeLock(ctx context.Context, lock types.ScrapeLock) error {
+	res, err := db.db.ExecContext(ctx, `
+		INSERT INTO scrape_locks (name, holder, run_id, acquired_at, expires_at)
+		VALUES ('scraper', ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			holder = excluded.holder, run_id = excluded.run_id,
+			acquired_at = excluded.acquired_at, expires_at = excluded.expires_at
+		WHERE scrape_locks.holder = excluded.holder OR scrape_locks.expires_at <= ?`,
+		lock.Holder, lock.RunID, formatTime(lock.AcquiredAt), formatTime(lock.ExpiresAt), formatTime(time.Now()))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	var current types.ScrapeLock
+	var acquiredAt, expiresAt string
+	err = db.db.QueryRowContext(ctx,
+		"SELECT holder, run_id, acquired_at, expires_at FROM scrape_locks WHERE name = 'scraper'").
+		Scan(&current.Holder, &current.RunID, &acquiredAt, &expiresAt)
+	if err != nil {
+		return err
+	}
+	current.AcquiredAt = parseTime(acquiredAt)
+	current.ExpiresAt = parseTime(expiresAt)
+	return &storage.LockedError{Current: current}
+}
+
+func (db *DB) ReleaseScrapeLock(ctx context.Context, holder string) error {
+	_, err := db.db.ExecContext(ctx, "DELETE FROM scrape_locks WHERE name = 'scraper' AND holder = ?", holder)
+	return err
+}
@@ -0,0 +1,144 @@
+package sqlite
+
+// sqlite implementation of every storage repository
+// for groups that want to self-host dormant on a single VPS without GCP.
+// uses modernc.org/sqlite (pure go) so no cgo toolchain is needed to build
+//
+// layout mirrors the firestore collections, see migrations/ for the schema
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+)
+
+// fixed width UTC so timestamps sort correctly as text
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+type DB struct {
+	db *sql.DB
+}
+
+// opens (or creates) the database file and applies pending migrations
+// path ":memory:" gives a throwaway database, handy for tests
+func Open(path string) (*DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows one writer at a time anyway
+	// a single connection also keeps ":memory:" databases from splitting per connection
+	conn.SetMaxOpenConns(1)
+
+	db := &DB{db: conn}
+	if err := db.migrate(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+
+	log.Printf("SQLite opened at %s", path)
+	return db, nil
+}
+
+// every repository backed by this database
+func (db *DB) Store() storage.Store {
+	return storage.Store{
+		Courses:    db,
+		Sections:   db,
+		Users:      db,
+		Schedules:  db,
+		Guests:     db,
+		Tokens:     db,
+		ScrapeRuns: db,
+		Schema:     db,
+	}
+}
+
+func (db *DB) Close() {
+	db.db.Close()
+}
+
+// runs fn in a transaction, rolling back if it returns an error
+func (db *DB) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// what *sql.DB and *sql.Tx have in common, for reads that run in or out of a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (db *DB) count(ctx context.Context, table string) (int64, error) {
+	var n int64
+	err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n)
+	return n, err
+}
+
+// "?, ?, ?" for IN clauses
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// single column of IDs
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(timeFormat, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// nested lists go in as JSON text
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func fromJSON(s string, v interface{}) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
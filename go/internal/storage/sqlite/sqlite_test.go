@@ -0,0 +1,483 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+func openTest(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestSectionsLinkToCourse(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	// no course yet, the link is skipped but the section is still saved
+	orphan := types.Section{ID: "1", CourseID: "CS110", Section: "001"}
+	if err := db.SaveSection(ctx, orphan); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetSectionsForCourse(ctx, "CS110"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("want ErrNotFound for missing course, got %v", err)
+	}
+
+	if err := db.SaveCourse(ctx, types.Course{ID: "CS110", Department: "CS", Code: "110"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"2", "3", "2"} {
+		s := types.Section{ID: id, CourseID: "CS110", Meetings: []types.Meeting{{Day: 1, StartTime: 600, EndTime: 675}}}
+		if err := db.SaveSection(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].ID != "2" || sections[1].ID != "3" {
+		t.Fatalf("unexpected sections %+v", sections)
+	}
+	if len(sections[0].Meetings) != 1 || sections[0].Meetings[0].StartTime != 600 {
+		t.Fatalf("meetings not round-tripped: %+v", sections[0].Meetings)
+	}
+
+	courses, err := db.ListCourses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 1 || len(courses[0].SectionIDs) != 2 {
+		t.Fatalf("unexpected courses %+v", courses)
+	}
+}
+
+func TestSaveCourseSections(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	course := types.Course{ID: "CS310", Department: "CS", Code: "310"}
+	pages := [][]types.Section{
+		{{ID: "1", CourseID: "CS310"}, {ID: "2", CourseID: "CS310"}},
+		{{ID: "3", CourseID: "CS310"}, {ID: "1", CourseID: "CS310"}},
+	}
+	for _, page := range pages {
+		if err := db.SaveCourseSections(ctx, course, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 3 || sections[2].ID != "3" {
+		t.Fatalf("unexpected sections %+v", sections)
+	}
+}
+
+func TestSameCRNInTwoTerms(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	var sections []types.Section
+	for _, term := range []string{"202610", "202670"} {
+		sections = append(sections, types.Section{ID: types.SectionID(term, "10001"), CourseID: "CS310", Term: term, CRN: "10001"})
+	}
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS310", Department: "CS"}, sections); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, term := range []string{"202610", "202670"} {
+		got, err := db.ListSections(ctx, term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].ID != term+"-10001" || got[0].CRN != "10001" {
+			t.Fatalf("%s: unexpected sections %+v", term, got)
+		}
+	}
+}
+
+func TestPruneSections(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	cs := types.Course{ID: "CS110", Department: "CS"}
+	if err := db.SaveCourseSections(ctx, cs, []types.Section{
+		{ID: "1", CourseID: "CS110", Term: "202610"},
+		{ID: "2", CourseID: "CS110", Term: "202610"}, // cancelled
+		{ID: "3", CourseID: "CS110", Term: "202570"}, // older term, out of scope
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// every section cancelled, the course should go away
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS499", Department: "CS"}, []types.Section{
+		{ID: "4", CourseID: "CS499", Term: "202610"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// subject that wasn't scraped
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "MATH113", Department: "MATH"}, []types.Section{
+		{ID: "5", CourseID: "MATH113", Term: "202610"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := db.PruneSections(ctx, "202610", []string{"CS"}, map[string]bool{"1": true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.DeletedSections) != 2 || len(summary.DeletedCourses) != 1 || summary.DeletedCourses[0] != "CS499" {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if summary.RemovedLinks != 1 {
+		t.Fatalf("want 1 dangling link removed from CS110, got %d", summary.RemovedLinks)
+	}
+
+	sections, err := db.GetSectionsForCourse(ctx, "CS110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("want sections 1 and 3 left, got %+v", sections)
+	}
+	if _, err := db.GetSectionsForCourse(ctx, "MATH113"); err != nil {
+		t.Fatalf("MATH113 should be untouched: %v", err)
+	}
+}
+
+func TestPruneLegacySections(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	// "9" was stored before sections had a term, it may well be a 202670 section
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS110", Department: "CS"}, []types.Section{
+		{ID: "1", CourseID: "CS110", Term: "202610"},
+		{ID: "2", CourseID: "CS110", Term: "202670"},
+		{ID: "9", CourseID: "CS110"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a run over two terms leaves it alone
+	summary, err := db.PruneSections(ctx, "202610", []string{"CS"}, map[string]bool{"1": true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.DeletedSections) != 0 {
+		t.Fatalf("deleted %v in a multi term run", summary.DeletedSections)
+	}
+
+	// a run over one term prunes it
+	summary, err = db.PruneSections(ctx, "202610", []string{"CS"}, map[string]bool{"1": true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(summary.DeletedSections) != "[9]" {
+		t.Fatalf("want only 9 deleted, got %v", summary.DeletedSections)
+	}
+}
+
+func TestUserRoleAndDelete(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	if err := db.SaveUser(ctx, types.User{ID: "u1", Name: "A", Role: types.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	// a later sign in without a role keeps the stored one
+	if err := db.SaveUser(ctx, types.User{ID: "u1", Name: "B"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := db.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "B" || user.Role != types.RoleAdmin {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	saved, err := db.SaveSchedule(ctx, "u1", types.Schedule{Name: "Plan A"})
+	if err != nil || saved.ID == "" {
+		t.Fatalf("save schedule: %+v %v", saved, err)
+	}
+
+	if err := db.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetUser(ctx, "u1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("want ErrNotFound after delete, got %v", err)
+	}
+	schedules, err := db.ListSchedules(ctx, "u1")
+	if err != nil || len(schedules) != 0 {
+		t.Fatalf("schedules left after delete: %+v %v", schedules, err)
+	}
+}
+
+func TestScheduleHistoryIsBounded(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	saves := storage.ScheduleHistoryLimit + 5
+	for i := 1; i <= saves; i++ {
+		plan := types.Schedule{ID: "plan-a", Name: fmt.Sprintf("v%d", i), Revision: i - 1}
+		if _, err := db.SaveSchedule(ctx, "u1", plan); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := db.ListScheduleVersions(ctx, "u1", "plan-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != storage.ScheduleHistoryLimit || versions[0].Version != saves {
+		t.Fatalf("got %d versions, newest %d", len(versions), versions[0].Version)
+	}
+	if _, err := db.GetScheduleVersion(ctx, "u1", "plan-a", 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("version 1 should have been dropped, got %v", err)
+	}
+
+	v, err := db.GetScheduleVersion(ctx, "u1", "plan-a", saves)
+	if err != nil || v.Schedule.Name != fmt.Sprintf("v%d", saves) {
+		t.Fatalf("latest version: %+v %v", v, err)
+	}
+}
+
+func TestStaleScheduleSaveConflicts(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	saved, err := db.SaveSchedule(ctx, "u1", types.Schedule{Name: "Plan A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two tabs load revision 1, the first save wins
+	tab := *saved
+	tab.Name = "from tab 1"
+	if _, err := db.SaveSchedule(ctx, "u1", tab); err != nil {
+		t.Fatal(err)
+	}
+
+	tab.Name = "from tab 2"
+	var conflict *storage.ConflictError
+	_, err = db.SaveSchedule(ctx, "u1", tab)
+	if !errors.As(err, &conflict) || !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("want a conflict, got %v", err)
+	}
+	if conflict.Current.Name != "from tab 1" || conflict.Current.Revision != 2 {
+		t.Fatalf("conflict should carry the stored schedule, got %+v", conflict.Current)
+	}
+
+	// a revision for a schedule that doesn't exist is stale too
+	if _, err := db.SaveSchedule(ctx, "u1", types.Schedule{ID: "gone", Revision: 3}); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("want a conflict for a missing schedule, got %v", err)
+	}
+}
+
+func TestScrapeChangeLog(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	// the hash has to survive a round trip or every section looks changed
+	course := types.Course{ID: "CS310", Department: "CS", Code: "310"}
+	section := types.Section{ID: "1", CourseID: "CS310", Term: "202610", Hash: "abc"}
+	if err := db.SaveCourseSections(ctx, course, []types.Section{section}); err != nil {
+		t.Fatal(err)
+	}
+	sections, err := db.ListSections(ctx, "202610")
+	if err != nil || len(sections) != 1 || sections[0].Hash != "abc" {
+		t.Fatalf("hash not stored: %+v %v", sections, err)
+	}
+
+	run := types.ScrapeRun{ID: "run1", Status: types.ScrapeSucceeded, Added: 1, Changed: 1, Unchanged: 5}
+	if err := db.SaveScrapeRun(ctx, run); err != nil {
+		t.Fatal(err)
+	}
+	changes := []types.SectionChange{
+		{SectionID: "2", CourseID: "MATH113", Kind: types.ChangeAdded},
+		{SectionID: "1", CourseID: "CS310", Kind: types.ChangeTime, Before: "Mon 9:00-10:15", After: "Tue 9:00-10:15"},
+	}
+	if err := db.SaveScrapeChanges(ctx, "run1", changes); err != nil {
+		t.Fatal(err)
+	}
+	// saving again replaces, it doesn't duplicate
+	if err := db.SaveScrapeChanges(ctx, "run1", changes[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.ListScrapeChanges(ctx, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].CourseID != "CS310" || got[0].After != "Tue 9:00-10:15" {
+		t.Fatalf("unexpected change log %+v", got)
+	}
+
+	saved, err := db.GetScrapeRun(ctx, "run1")
+	if err != nil || saved.Added != 1 || saved.Changed != 1 || saved.Unchanged != 5 {
+		t.Fatalf("counters not stored: %+v %v", saved, err)
+	}
+}
+
+func TestSectionsByInstructor(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	smith := types.Instructor{ID: "jsmith@gmu.edu", Name: "Smith, John", Email: "jsmith@gmu.edu", Primary: true}
+	doe := types.Instructor{ID: "jdoe@gmu.edu", Name: "Doe, Jane", Email: "jdoe@gmu.edu"}
+	course := types.Course{ID: "CS310", Department: "CS", Code: "310"}
+	sections := []types.Section{
+		{ID: "1", CourseID: "CS310", Term: "202610", Instructors: []types.Instructor{smith, doe}},
+		{ID: "2", CourseID: "CS310", Term: "202610", Instructors: []types.Instructor{doe}},
+		{ID: "3", CourseID: "CS310", Term: "202670", Instructors: []types.Instructor{smith}},
+	}
+	if err := db.SaveCourseSections(ctx, course, sections); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(instructorID, term string) string {
+		t.Helper()
+		found, err := db.ListSectionsByInstructor(ctx, instructorID, term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range found {
+			got = append(got, s.ID)
+		}
+		return fmt.Sprint(got)
+	}
+	if got := ids("jsmith@gmu.edu", ""); got != "[1 3]" {
+		t.Errorf("smith = %s", got)
+	}
+	if got := ids("jsmith@gmu.edu", "202610"); got != "[1]" {
+		t.Errorf("smith in 202610 = %s", got)
+	}
+	// a search by name doesn't know the email
+	if got := ids(types.InstructorID("Smith,  John", ""), ""); got != "[1 3]" {
+		t.Errorf("smith by name = %s", got)
+	}
+
+	// doe is dropped from section 1, and section 2 goes away
+	sections[0].Instructors = []types.Instructor{smith}
+	if err := db.SaveCourseSections(ctx, course, sections[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PruneSections(ctx, "202610", []string{"CS"}, map[string]bool{"1": true}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("jdoe@gmu.edu", ""); got != "[]" {
+		t.Errorf("doe after changes = %s", got)
+	}
+	if got := ids("name:doe, jane", ""); got != "[]" {
+		t.Errorf("doe by name after changes = %s", got)
+	}
+
+	found, err := db.ListSectionsByInstructor(ctx, "jsmith@gmu.edu", "202610")
+	if err != nil || len(found) != 1 || len(found[0].Instructors) != 1 || found[0].Instructors[0] != smith {
+		t.Fatalf("instructors not round-tripped: %+v %v", found, err)
+	}
+}
+
+// sections indexed before name keys only get them once they're rewritten
+func TestMigrateIndexesInstructorNames(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+
+	smith := types.Instructor{ID: "jsmith@gmu.edu", Name: "Smith, John", Email: "jsmith@gmu.edu", Primary: true}
+	section := types.Section{ID: "202610-1", CourseID: "CS310", Term: "202610", CRN: "1", Instructors: []types.Instructor{smith}}
+	if err := db.SaveCourseSections(ctx, types.Course{ID: "CS310", Department: "CS"}, []types.Section{section}); err != nil {
+		t.Fatal(err)
+	}
+
+	// what a version 3 write left behind
+	for _, q := range []string{
+		"UPDATE sections SET schema_version = 3",
+		"DELETE FROM section_instructors WHERE instructor_id LIKE 'name:%'",
+	} {
+		if _, err := db.db.ExecContext(ctx, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if found, _ := db.ListSectionsByInstructor(ctx, "name:smith, john", ""); len(found) != 0 {
+		t.Fatalf("setup: %+v", found)
+	}
+
+	if _, err := db.MigrateDocuments(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	found, err := db.ListSectionsByInstructor(ctx, "name:smith, john", "")
+	if err != nil || len(found) != 1 || found[0].ID != section.ID {
+		t.Fatalf("by name after migrate: %+v %v", found, err)
+	}
+}
+
+func TestScrapeLock(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t)
+	now := time.Now()
+
+	lease := func(holder string, ttl time.Duration) types.ScrapeLock {
+		return types.ScrapeLock{Holder: holder, RunID: holder + "-run", AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	}
+
+	if err := db.AcquireScrapeLock(ctx, lease("a", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// renewing our own lease is fine
+	if err := db.AcquireScrapeLock(ctx, lease("a", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	var locked *storage.LockedError
+	err := db.AcquireScrapeLock(ctx, lease("b", time.Minute))
+	if !errors.As(err, &locked) || locked.Current.Holder != "a" || locked.Current.RunID != "a-run" {
+		t.Fatalf("want LockedError held by a, got %v", err)
+	}
+
+	// releasing someone else's lease does nothing
+	if err := db.ReleaseScrapeLock(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcquireScrapeLock(ctx, lease("b", time.Minute)); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("want ErrLocked after a foreign release, got %v", err)
+	}
+
+	if err := db.ReleaseScrapeLock(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	// b takes it, then lets it expire and c takes over
+	if err := db.AcquireScrapeLock(ctx, lease("b", -time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcquireScrapeLock(ctx, lease("c", time.Minute)); err != nil {
+		t.Fatalf("expired lease wasn't taken over: %v", err)
+	}
+}
+
+func TestMigrationsRunOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dormant.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("open #%d: %v", i+1, err)
+		}
+		db.Close()
+	}
+}
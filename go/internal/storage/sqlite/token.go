@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+const tokenColumns = "id, user_id, name, hash, read_only, created_at, last_used_at"
+
+func (db *DB) SaveToken(ctx context.Context, token types.APIToken) error {
+	_, err := db.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO api_tokens ("+tokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.Name, token.Hash, token.ReadOnly,
+		formatTime(token.CreatedAt), formatTime(token.LastUsedAt))
+	return err
+}
+
+func (db *DB) GetToken(ctx context.Context, tokenID string) (*types.APIToken, error) {
+	row := db.db.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM api_tokens WHERE id = ?", tokenID)
+
+	token, err := scanToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	return token, err
+}
+
+func (db *DB) ListTokens(ctx context.Context, userID string) ([]types.APIToken, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT "+tokenColumns+" FROM api_tokens WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []types.APIToken{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// scoped to the owner so we don't leak whether someone else's token exists
+func (db *DB) DeleteToken(ctx context.Context, userID, tokenID string) error {
+	res, err := db.db.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenID, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func (db *DB) TouchToken(ctx context.Context, tokenID string) error {
+	res, err := db.db.ExecContext(ctx,
+		"UPDATE api_tokens SET last_used_at = ? WHERE id = ?", formatTime(time.Now()), tokenID)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func scanToken(row scanner) (*types.APIToken, error) {
+	var token types.APIToken
+	var createdAt, lastUsedAt string
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Hash, &token.ReadOnly, &createdAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.CreatedAt = parseTime(createdAt)
+	token.LastUsedAt = parseTime(lastUsedAt)
+	return &token, nil
+}
+
+// ErrNotFound when a write matched nothing
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/schema"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/storage"
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// upserts the user, an empty role keeps the stored one (same as firestore.MergeAll)
+func (db *DB) SaveUser(ctx context.Context, user types.User) error {
+	_, err := db.db.ExecContext(ctx, `
+		INSERT INTO users (id, name, email, avatar_url, role)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			email = excluded.email,
+			avatar_url = excluded.avatar_url,
+			role = CASE WHEN excluded.role = '' THEN users.role ELSE excluded.role END`,
+		user.ID, user.Name, user.Email, user.AvatarURL, user.Role)
+	return err
+}
+
+func (db *DB) GetUser(ctx context.Context, userID string) (*types.User, error) {
+	var user types.User
+	err := db.db.QueryRowContext(ctx,
+		"SELECT id, name, email, avatar_url, role FROM users WHERE id = ?", userID).
+		Scan(&user.ID, &user.Name, &user.Email, &user.AvatarURL, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (db *DB) ListUsers(ctx context.Context) ([]types.User, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id, name, email, avatar_url, role FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+	for rows.Next() {
+		var user types.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.AvatarURL, &user.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (db *DB) CountUsers(ctx context.Context) (int64, error) {
+	return db.count(ctx, "users")
+}
+
+// removes the user with their schedules and API tokens in one transaction
+func (db *DB) DeleteUser(ctx context.Context, userID string) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		for _, q := range []string{
+			"DELETE FROM schedules WHERE user_id = ?",
+			"DELETE FROM schedule_versions WHERE user_id = ?",
+			"DELETE FROM api_tokens WHERE user_id = ?",
+			"DELETE FROM users WHERE id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, q, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saves a schedule under the user, generating an ID if it has none
+// the revision check, the write and the new version all happen in one transaction
+func (db *DB) SaveSchedule(ctx context.Context, userID string, schedule types.Schedule) (*types.Schedule, error) {
+	if schedule.ID == "" {
+		schedule.ID = newID()
+	}
+	schema.StampSchedule(&schedule)
+
+	sections, err := toJSON(schedule.Sections)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.withTx(ctx, func(tx *sql.Tx) error {
+		// a missing schedule counts as revision 0
+		current, err := getSchedule(ctx, tx, userID, schedule.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			if schedule.Revision != 0 {
+				return &storage.ConflictError{}
+			}
+		} else if err != nil {
+			return err
+		} else if current.Revision != schedule.Revision {
+			return &storage.ConflictError{Current: current}
+		}
+
+		var next int
+		err = tx.QueryRowContext(ctx,
+			"SELECT COALESCE(MAX(version), 0) + 1 FROM schedule_versions WHERE user_id = ? AND schedule_id = ?",
+			userID, schedule.ID).Scan(&next)
+		if err != nil {
+			return err
+		}
+
+		schedule.UserID = userID
+		schedule.Revision = next
+		schedule.UpdatedAt = time.Now()
+		updatedAt := formatTime(schedule.UpdatedAt)
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO schedules (user_id, id, name, sections, schema_version, revision, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID, schedule.ID, schedule.Name, sections, schedule.SchemaVersion, schedule.Revision, updatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO schedule_versions (user_id, schedule_id, version, saved_at, name, sections, schema_version)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID, schedule.ID, next, updatedAt, schedule.Name, sections, schedule.SchemaVersion)
+		if err != nil {
+			return err
+		}
+
+		// drop what fell out of the history
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM schedule_versions WHERE user_id = ? AND schedule_id = ? AND version <= ?",
+			userID, schedule.ID, next-storage.ScheduleHistoryLimit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (db *DB) GetSchedule(ctx context.Context, userID, scheduleID string) (*types.Schedule, error) {
+	return getSchedule(ctx, db.db, userID, scheduleID)
+}
+
+// q is the *sql.DB or the *sql.Tx doing the revision check
+func getSchedule(ctx context.Context, q querier, userID, scheduleID string) (*types.Schedule, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT "+scheduleColumns+" FROM schedules WHERE user_id = ? AND id = ?", userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules, err := scanSchedules(rows, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return &schedules[0], nil
+}
+
+const versionColumns = "version, saved_at, name, sections, schema_version"
+
+// newest first
+func (db *DB) ListScheduleVersions(ctx context.Context, userID, scheduleID string) ([]types.ScheduleVersion, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT "+versionColumns+" FROM schedule_versions WHERE user_id = ? AND schedule_id = ? ORDER BY version DESC",
+		userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []types.ScheduleVersion{}
+	for rows.Next() {
+		v, err := scanScheduleVersion(rows, userID, scheduleID)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+	return versions, rows.Err()
+}
+
+func (db *DB) GetScheduleVersion(ctx context.Context, userID, scheduleID string, version int) (*types.ScheduleVersion, error) {
+	row := db.db.QueryRowContext(ctx,
+		"SELECT "+versionColumns+" FROM schedule_versions WHERE user_id = ? AND schedule_id = ? AND version = ?",
+		userID, scheduleID, version)
+
+	v, err := scanScheduleVersion(row, userID, scheduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	return v, err
+}
+
+func scanScheduleVersion(row scanner, userID, scheduleID string) (*types.ScheduleVersion, error) {
+	v := types.ScheduleVersion{Schedule: types.Schedule{ID: scheduleID, UserID: userID}}
+	var savedAt, sections string
+
+	err := row.Scan(&v.Version, &savedAt, &v.Schedule.Name, &sections, &v.Schedule.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	v.SavedAt = parseTime(savedAt)
+	v.Schedule.Revision = v.Version
+	v.Schedule.UpdatedAt = v.SavedAt
+	if err := fromJSON(sections, &v.Schedule.Sections); err != nil {
+		return nil, err
+	}
+	schema.UpgradeSchedule(&v.Schedule)
+	return &v, nil
+}
+
+func (db *DB) ListSchedules(ctx context.Context, userID string) ([]types.Schedule, error) {
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT "+scheduleColumns+" FROM schedules WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSchedules(rows, userID)
+}
+
+const scheduleColumns = "id, name, sections, schema_version, revision, updated_at"
+
+// shared by user and guest schedules, both select scheduleColumns
+// (guests have no revision column and select 0 in its place)
+func scanSchedules(rows *sql.Rows, userID string) ([]types.Schedule, error) {
+	schedules := []types.Schedule{}
+	for rows.Next() {
+		s := types.Schedule{UserID: userID}
+		var sections, updatedAt string
+		if err := rows.Scan(&s.ID, &s.Name, &sections, &s.SchemaVersion, &s.Revision, &updatedAt); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(sections, &s.Sections); err != nil {
+			return nil, err
+		}
+		s.UpdatedAt = parseTime(updatedAt)
+		schema.UpgradeSchedule(&s)
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// 20 hex chars, close enough to a firestore auto ID
+func newID() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,219 @@
+package storage
+
+// repository interfaces for everything we persist
+// handlers, the catalog cache, the scheduler and the scraper only talk to these,
+// so the backend (firestore today) can be swapped out or faked in tests
+//
+// the firestore package is one implementation, see firestore.Open
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Google-Developer-Groups-GMU/dormant/go/internal/types"
+)
+
+// returned by every backend when the requested document doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// returned when a write was based on a revision that's no longer the stored one
+var ErrConflict = errors.New("revision conflict")
+
+// ErrConflict with the schedule as it's stored now, so the caller can show it or rebase on it
+type ConflictError struct {
+	Current *types.Schedule // nil if the schedule no longer exists
+}
+
+func (e *ConflictError) Error() string {
+	if e.Current == nil {
+		return "schedule was deleted since it was loaded"
+	}
+	return fmt.Sprintf("schedule %s is at revision %d", e.Current.ID, e.Current.Revision)
+}
+
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
+// returned when a lock is held by someone else
+var ErrLocked = errors.New("locked")
+
+// ErrLocked with the lease that's in the way
+type LockedError struct {
+	Current types.ScrapeLock
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("held by %s (run %s) until %s",
+		e.Current.Holder, e.Current.RunID, e.Current.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error { return ErrLocked }
+
+// one document that didn't make it in a multi-document write
+type WriteFailure struct {
+	Collection string // "sections"
+	ID         string // "10492"
+	Err        error
+}
+
+// returned by batched writes, lists every document that failed
+// documents not listed here were written
+type BatchError struct {
+	Failures []WriteFailure
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 0 {
+		return "batch write failed"
+	}
+	f := e.Failures[0]
+	return fmt.Sprintf("%d documents failed to write, first %s/%s: %v", len(e.Failures), f.Collection, f.ID, f.Err)
+}
+
+// what a prune changed, IDs are listed so they can be logged
+type PruneSummary struct {
+	DeletedSections []string
+	DeletedCourses  []string
+	RemovedLinks    int // section_ids entries removed from courses that were kept
+}
+
+// courses/{courseID}
+type CourseRepository interface {
+	// overwrites the course if it already exists
+	SaveCourse(ctx context.Context, course types.Course) error
+	ListCourses(ctx context.Context) ([]types.Course, error)
+	CountCourses(ctx context.Context) (int64, error)
+}
+
+// sections/{sectionID}, linked from courses/{courseID}.section_ids
+type SectionRepository interface {
+	// saves the section and links it to its parent course
+	// the link is skipped (with a warning) if the course doesn't exist yet
+	SaveSection(ctx context.Context, section types.Section) error
+	// saves sections of one course together with the course itself, atomically
+	// the course is created if missing, otherwise its metadata is updated and existing links are kept.
+	// per-document failures come back as a *BatchError
+	SaveCourseSections(ctx context.Context, course types.Course, sections []types.Section) error
+	// deletes sections of the term under courses in subjects that aren't in seen,
+	// removes dangling section_ids from those courses and drops courses left with no sections.
+	// sections with no term (stored before terms were) are only pruned when legacy is set,
+	// which the scraper does when the run covers a single term, otherwise they might belong to another one
+	PruneSections(ctx context.Context, term string, subjects []string, seen map[string]bool, legacy bool) (PruneSummary, error)
+	// sections that no longer exist are skipped, not reported as errors
+	GetSectionsForCourse(ctx context.Context, courseID string) ([]types.Section, error)
+	// every stored section, an empty term means all terms
+	ListSections(ctx context.Context, term string) ([]types.Section, error)
+	// sections taught by the instructor (a types.InstructorID), an empty term means all terms
+	// goes through an index, not a scan: instructor_ids on firestore, the section_instructors table on sqlite
+	ListSectionsByInstructor(ctx context.Context, instructorID, term string) ([]types.Section, error)
+	CountSections(ctx context.Context) (int64, error)
+}
+
+// users/{userID}
+type UserRepository interface {
+	// creates the user or merges profile fields, an empty role is left untouched
+	SaveUser(ctx context.Context, user types.User) error
+	GetUser(ctx context.Context, userID string) (*types.User, error)
+	ListUsers(ctx context.Context) ([]types.User, error)
+	CountUsers(ctx context.Context) (int64, error)
+	// removes the user and everything stored under them
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// how many saves of each schedule are kept, older ones are dropped
+const ScheduleHistoryLimit = 20
+
+// users/{userID}/schedules/{scheduleID}
+// every save is also recorded under users/{userID}/schedules/{scheduleID}/versions/{version}
+// writes are optimistic: schedule.Revision has to match the stored revision (0 for a new schedule),
+// otherwise nothing is written and a *ConflictError comes back
+type ScheduleRepository interface {
+	// an empty schedule ID gets a new one
+	// returns the schedule as stored, with its ID, new revision and updated_at
+	SaveSchedule(ctx context.Context, userID string, schedule types.Schedule) (*types.Schedule, error)
+	GetSchedule(ctx context.Context, userID, scheduleID string) (*types.Schedule, error)
+	ListSchedules(ctx context.Context, userID string) ([]types.Schedule, error)
+	// newest first, empty if the schedule was never saved
+	ListScheduleVersions(ctx context.Context, userID, scheduleID string) ([]types.ScheduleVersion, error)
+	// ErrNotFound if the version was never saved or has been dropped from the history
+	GetScheduleVersion(ctx context.Context, userID, scheduleID string, version int) (*types.ScheduleVersion, error)
+}
+
+// guests/{guestID}/schedules/{scheduleID}
+type GuestRepository interface {
+	SaveGuestSchedule(ctx context.Context, guestID string, schedule types.Schedule) (string, error)
+	ListGuestSchedules(ctx context.Context, guestID string) ([]types.Schedule, error)
+	// removes one schedule, a no-op if it's already gone
+	DeleteGuestSchedule(ctx context.Context, guestID, scheduleID string) error
+	DeleteGuest(ctx context.Context, guestID string) error
+}
+
+// api_tokens/{tokenID}
+type TokenRepository interface {
+	SaveToken(ctx context.Context, token types.APIToken) error
+	GetToken(ctx context.Context, tokenID string) (*types.APIToken, error)
+	ListTokens(ctx context.Context, userID string) ([]types.APIToken, error)
+	// ErrNotFound if the token belongs to another user
+	DeleteToken(ctx context.Context, userID, tokenID string) error
+	TouchToken(ctx context.Context, tokenID string) error
+}
+
+// scrape_runs/{runID}
+type ScrapeRunRepository interface {
+	SaveScrapeRun(ctx context.Context, run types.ScrapeRun) error
+	// newest first
+	ListScrapeRuns(ctx context.Context, limit int) ([]types.ScrapeRun, error)
+	GetScrapeRun(ctx context.Context, runID string) (*types.ScrapeRun, error)
+	// the run's change log, saving again replaces entries with the same section and kind
+	SaveScrapeChanges(ctx context.Context, runID string, changes []types.SectionChange) error
+	// ordered by course, section and kind, empty if the run changed nothing
+	ListScrapeChanges(ctx context.Context, runID string) ([]types.SectionChange, error)
+
+	// takes scrape_locks/scraper if it's free, expired, or already lock.Holder's (which renews it)
+	// checked and written atomically, a *LockedError comes back if someone else holds it
+	AcquireScrapeLock(ctx context.Context, lock types.ScrapeLock) error
+	// gives the lock up, does nothing if holder doesn't hold it anymore
+	ReleaseScrapeLock(ctx context.Context, holder string) error
+}
+
+// the order ListScrapeChanges returns, for backends that can't sort on read
+func SortChanges(changes []types.SectionChange) {
+	slices.SortFunc(changes, func(a, b types.SectionChange) int {
+		return cmp.Or(
+			cmp.Compare(a.CourseID, b.CourseID),
+			cmp.Compare(a.SectionID, b.SectionID),
+			cmp.Compare(a.Kind, b.Kind),
+		)
+	})
+}
+
+// what a document migration found in one collection
+type MigrationResult struct {
+	Collection string
+	Scanned    int
+	Outdated   int      // behind the current schema version
+	Upgraded   int      // rewritten in place, always 0 on a dry run
+	Failed     []string // IDs that couldn't be rewritten
+}
+
+// courses, sections and schedules are stored as-is and can lag behind internal/schema
+type SchemaRepository interface {
+	// rewrites every outdated document with the current shape
+	// with dryRun nothing is written, the results say what would change
+	MigrateDocuments(ctx context.Context, dryRun bool) ([]MigrationResult, error)
+}
+
+// bundle of every repository, this is what gets passed around
+type Store struct {
+	Courses    CourseRepository
+	Sections   SectionRepository
+	Users      UserRepository
+	Schedules  ScheduleRepository
+	Guests     GuestRepository
+	Tokens     TokenRepository
+	ScrapeRuns ScrapeRunRepository
+	Schema     SchemaRepository
+}
@@ -0,0 +1,21 @@
+package types
+
+import "time"
+
+// everything we store about a user, for the self-service export
+type AccountExport struct {
+	ExportedAt time.Time  `json:"exported_at"`
+	Profile    User       `json:"profile"`
+	APITokens  []APIToken `json:"api_tokens"`
+
+	// per-user data laid out like the subcollections under users/{userID} ex) "schedules"
+	// see storage.ExportUser
+	Collections map[string][]ExportedDoc `json:"collections"`
+}
+
+// a raw stored document plus its own nested subcollections
+type ExportedDoc struct {
+	ID          string                   `json:"id"`
+	Data        map[string]interface{}   `json:"data"`
+	Collections map[string][]ExportedDoc `json:"collections,omitempty"`
+}
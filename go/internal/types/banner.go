@@ -5,6 +5,11 @@ type BannerSubject struct {
 	Description string `json:"description"`
 }
 
+type BannerTerm struct {
+	Code        string `json:"code"`        // "202610"
+	Description string `json:"description"` // "Spring 2026", "Fall 2025 (View Only)"
+}
+
 type BannerResponse struct {
 	Success    bool            `json:"success"`
 	TotalCount int             `json:"totalCount"`
@@ -21,30 +26,38 @@ type BannerSection struct {
 	SequenceNumber string `json:"sequenceNumber"`
 	Title          string `json:"courseTitle"`
 
-	Faculty []struct {
+	Faculty []BannerFaculty `json:"faculty"`
+
+	// the nested meetings array is the most important
+	MeetingsFaculty []BannerMeetingFaculty `json:"meetingsFaculty"`
+}
+
+type BannerFaculty struct {
+	DisplayName string `json:"displayName"` // "Smith, John"
+	Email       string `json:"emailAddress"`
+	Primary     bool   `json:"primaryIndicator"`
+}
+
+// one meeting pattern of a section and who teaches it
+type BannerMeetingFaculty struct {
+	MeetingTime BannerMeetingTime `json:"meetingTime"`
+	Faculty     []struct {
 		DisplayName string `json:"displayName"`
-		Email       string `json:"emailAddress"`
+		Email       string `json:"email"`
 	} `json:"faculty"`
+}
 
-	// the nested meetings array is the most important
-	MeetingsFaculty []struct {
-		MeetingTime struct {
-			BeginTime *string `json:"beginTime"` // "1000" (HHMM)
-			EndTime   *string `json:"endTime"`   // "1115"
-			Building  *string `json:"building"`
-			Room      *string `json:"room"`
-
-			Monday    bool `json:"monday"`
-			Tuesday   bool `json:"tuesday"`
-			Wednesday bool `json:"wednesday"`
-			Thursday  bool `json:"thursday"`
-			Friday    bool `json:"friday"`
-			Saturday  bool `json:"saturday"`
-			Sunday    bool `json:"sunday"`
-		} `json:"meetingTime"`
-		Faculty []struct {
-			DisplayName string `json:"displayName"`
-			Email       string `json:"email"`
-		} `json:"faculty"`
-	} `json:"meetingsFaculty"`
+type BannerMeetingTime struct {
+	BeginTime *string `json:"beginTime"` // "1000" (HHMM)
+	EndTime   *string `json:"endTime"`   // "1115"
+	Building  *string `json:"building"`
+	Room      *string `json:"room"`
+
+	Monday    bool `json:"monday"`
+	Tuesday   bool `json:"tuesday"`
+	Wednesday bool `json:"wednesday"`
+	Thursday  bool `json:"thursday"`
+	Friday    bool `json:"friday"`
+	Saturday  bool `json:"saturday"`
+	Sunday    bool `json:"sunday"`
 }
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
 type Course struct {
 	ID          string `json:"id" firestore:"id"`
 	Department  string `json:"department" firestore:"department"` // "CS"
@@ -10,16 +16,79 @@ type Course struct {
 
 	// list of section IDs for this course
 	SectionIDs []string `json:"section_ids" firestore:"section_ids"`
+
+	// shape of the stored document, see internal/schema
+	SchemaVersion int `json:"schema_version" firestore:"schema_version"`
 }
 
 type Section struct {
-	ID        string `json:"id" firestore:"id"`               // CRN as the ID ex) "10492"
+	ID        string `json:"id" firestore:"id"`               // see SectionID ex) "202610-10492", the bare CRN on sections saved before that
 	CourseID  string `json:"course_id" firestore:"course_id"` // "CS110"
 	Section   string `json:"section" firestore:"section"`     // "001"
 	Professor string `json:"professor" firestore:"professor"`
+	Term      string `json:"term" firestore:"term"` // banner term code ex) "202610", empty on sections saved before terms were tracked
+	CRN       string `json:"crn" firestore:"crn"`   // "10492", only unique within a term
+
+	// everyone teaching the section, primary instructor first
+	// Professor is the first one's name ("TBA" without any), for clients that show a single name
+	Instructors []Instructor `json:"instructors" firestore:"instructors"`
+	// InstructorKeys of Instructors, firestore can only query a flat array ex) array-contains
+	// filled in by the backend on write, see storage.SectionRepository.ListSectionsByInstructor
+	InstructorIDs []string `json:"-" firestore:"instructor_ids"`
 
 	// backend data for algorithm
 	Meetings []Meeting `json:"meetings" firestore:"meetings"`
+
+	// fingerprint of the fields above, set by the scraper so unchanged sections aren't rewritten
+	// empty on sections saved before it was tracked
+	Hash string `json:"hash,omitempty" firestore:"hash,omitempty"`
+
+	SchemaVersion int `json:"schema_version" firestore:"schema_version"`
+	// SchemaVersion as it was stored, before the backend upgraded the section on read
+	// the scraper rewrites outdated sections even when nothing in them changed
+	StoredVersion int `json:"-" firestore:"-"`
+}
+
+// key of a section, banner reuses CRNs across terms so the CRN alone isn't enough
+func SectionID(term, crn string) string {
+	return term + "-" + crn
+}
+
+type Instructor struct {
+	ID      string `json:"id" firestore:"id"`                           // see InstructorID
+	Name    string `json:"name" firestore:"name"`                       // "Smith, John"
+	Email   string `json:"email,omitempty" firestore:"email,omitempty"` // empty when banner doesn't have one
+	Primary bool   `json:"primary" firestore:"primary"`
+}
+
+// stable key for an instructor across sections and terms
+// the email when there is one, since names aren't unique, otherwise the normalized name
+// ex) "jsmith@gmu.edu", "name:smith, john"
+func InstructorID(name, email string) string {
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		return email
+	}
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		return ""
+	}
+	return "name:" + name
+}
+
+// every key a section with these instructors can be looked up by
+// an instructor with an email is found by their name as well, a search by name doesn't know the email
+func InstructorKeys(instructors []Instructor) []string {
+	keys := make([]string, 0, len(instructors))
+	add := func(key string) {
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, in := range instructors {
+		add(in.ID)
+		add(InstructorID(in.Name, ""))
+	}
+	return keys
 }
 
 type Meeting struct {
@@ -34,4 +103,11 @@ type Schedule struct {
 	UserID   string    `json:"user_id" firestore:"user_id"`
 	Name     string    `json:"name" firestore:"name"`
 	Sections []Section `json:"sections" firestore:"sections"`
+
+	// bumped on every save (it's the history version number), clients send back the one they loaded
+	// so two tabs can't silently overwrite each other. guest schedules don't use it
+	Revision  int       `json:"revision" firestore:"revision"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+
+	SchemaVersion int `json:"schema_version" firestore:"schema_version"`
 }
@@ -0,0 +1,11 @@
+package types
+
+import "time"
+
+// one save of a schedule, kept so students can go back to an older plan
+// the newest version is always what's currently saved
+type ScheduleVersion struct {
+	Version  int       `json:"version" firestore:"version"` // 1, 2, 3, ... per schedule
+	SavedAt  time.Time `json:"saved_at" firestore:"saved_at"`
+	Schedule Schedule  `json:"schedule" firestore:"schedule"`
+}
@@ -0,0 +1,68 @@
+package types
+
+import "time"
+
+// scrape run statuses
+const (
+	ScrapeRunning   string = "running"
+	ScrapeSucceeded string = "succeeded"
+	ScrapePartial   string = "partial" // some subjects failed, see errors
+	ScrapeFailed    string = "failed"
+)
+
+// record of a single scraper run
+// scrape_runs/{runID}
+type ScrapeRun struct {
+	ID         string    `json:"id" firestore:"id"`             // "20260115T103000.123Z"
+	Trigger    string    `json:"trigger" firestore:"trigger"`   // "cli", "admin:{userID}"
+	Terms      []string  `json:"terms" firestore:"terms"`       // empty until resolved when scraping every open term
+	Subjects   []string  `json:"subjects" firestore:"subjects"` // same, when scraping every subject
+	Status     string    `json:"status" firestore:"status"`
+	StartedAt  time.Time `json:"started_at" firestore:"started_at"`
+	FinishedAt time.Time `json:"finished_at" firestore:"finished_at"` // zero while running
+
+	// counts of documents written
+	Courses  int `json:"courses" firestore:"courses"`
+	Sections int `json:"sections" firestore:"sections"`
+
+	// what the run found compared to what was stored, details in the change log
+	Added     int `json:"added" firestore:"added"`
+	Changed   int `json:"changed" firestore:"changed"` // sections, a section with a new time and instructor counts once
+	Removed   int `json:"removed" firestore:"removed"`
+	Unchanged int `json:"unchanged" firestore:"unchanged"` // not rewritten
+
+	Errors []string `json:"errors" firestore:"errors"`
+}
+
+// kinds of section changes
+const (
+	ChangeAdded      string = "added"
+	ChangeRemoved    string = "removed"
+	ChangeTime       string = "time_changed"
+	ChangeInstructor string = "instructor_changed"
+	ChangeOther      string = "other_changed" // section number, location, ...
+)
+
+// one entry of a run's change log
+// scrape_runs/{runID}/changes/{sectionID}_{kind}
+type SectionChange struct {
+	SectionID string `json:"section_id" firestore:"section_id"`
+	CourseID  string `json:"course_id" firestore:"course_id"`
+	Section   string `json:"section" firestore:"section"` // "001"
+	Term      string `json:"term" firestore:"term"`
+	Kind      string `json:"kind" firestore:"kind"`
+
+	// readable old and new value ex) "Mon 10:30-11:45", empty for added and removed
+	Before string `json:"before,omitempty" firestore:"before,omitempty"`
+	After  string `json:"after,omitempty" firestore:"after,omitempty"`
+}
+
+// lease that keeps scrapes in different processes from overlapping
+// the holder renews it while its run goes, a crashed holder's lease just runs out
+// scrape_locks/scraper
+type ScrapeLock struct {
+	Holder     string    `json:"holder" firestore:"holder"` // "{hostname}:{pid}:{random}"
+	RunID      string    `json:"run_id" firestore:"run_id"`
+	AcquiredAt time.Time `json:"acquired_at" firestore:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at" firestore:"expires_at"`
+}
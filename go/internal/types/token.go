@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// personal API token for scripted access
+// only the hash of the secret is stored, the plain token is shown once at creation
+type APIToken struct {
+	ID         string    `json:"id" firestore:"id"`
+	UserID     string    `json:"user_id" firestore:"user_id"`
+	Name       string    `json:"name" firestore:"name"`           // "my notebook"
+	Hash       string    `json:"-" firestore:"hash"`              // sha256 of the secret, never sent to clients
+	ReadOnly   bool      `json:"read_only" firestore:"read_only"` // GET only when true
+	CreatedAt  time.Time `json:"created_at" firestore:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" firestore:"last_used_at"` // zero if never used
+}
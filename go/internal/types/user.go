@@ -1,8 +1,21 @@
 package types
 
+// user roles
+// missing role on older documents means RoleUser
+const (
+	RoleUser  string = "user"
+	RoleAdmin string = "admin"
+)
+
 type User struct {
 	ID        string `json:"id" firestore:"id"`
 	Name      string `json:"name" firestore:"name"`
 	Email     string `json:"email" firestore:"email"`
 	AvatarURL string `json:"avatar_url" firestore:"avatar_url"`
+	Role      string `json:"role" firestore:"role"`
+}
+
+// reports whether the user can access admin operations
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }